@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleTeamLead Role = "team-lead"
+	RoleMember   Role = "member"
+	RoleBot      Role = "bot"
+)
+
+type Permission string
+
+const (
+	PermTeamsRead  Permission = "teams:read"
+	PermTeamsWrite Permission = "teams:write"
+	PermPRRead     Permission = "pr:read"
+	PermPRWrite    Permission = "pr:write"
+	PermUsersRead  Permission = "users:read"
+	PermUsersAdmin Permission = "users:admin"
+	PermStatsRead  Permission = "stats:read"
+	PermKeysAdmin  Permission = "apikeys:admin"
+	PermAuditRead  Permission = "audit:read"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermTeamsRead, PermTeamsWrite, PermPRRead, PermPRWrite,
+		PermUsersRead, PermUsersAdmin, PermStatsRead, PermKeysAdmin, PermAuditRead,
+	},
+	// users:admin у тимлида ограничен своей командой - это проверяет сервис
+	RoleTeamLead: {
+		PermTeamsRead, PermPRRead, PermPRWrite,
+		PermUsersRead, PermUsersAdmin, PermStatsRead,
+	},
+	RoleMember: {
+		PermTeamsRead, PermPRRead, PermPRWrite, PermUsersRead, PermStatsRead,
+	},
+	RoleBot: {
+		PermTeamsRead, PermPRRead, PermPRWrite, PermStatsRead,
+	},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Grants(perm Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Principal - тот, от чьего имени выполняется запрос.
+// Scopes дополнительно сужают права роли; пустой список - все права роли
+type Principal struct {
+	KeyID    string
+	Name     string
+	Role     Role
+	Scopes   []Permission
+	UserID   string
+	TeamName string
+}
+
+func (p *Principal) Can(perm Permission) bool {
+	if !p.Role.Grants(perm) {
+		return false
+	}
+	if len(p.Scopes) == 0 {
+		return true
+	}
+	for _, scope := range p.Scopes {
+		if scope == perm {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext возвращает nil для запросов без аутентификации
+// (например, внутренние вызовы сервиса)
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
+
+// HashKey - в БД хранится только sha256 от секрета
+func HashKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate random bytes: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func GenerateKeyID() (string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", err
+	}
+	return "key_" + id, nil
+}
+
+func GenerateSecret() (string, error) {
+	secret, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	return "prs_" + secret, nil
+}
@@ -0,0 +1,203 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// допустимое расхождение часов с провайдером
+const clockSkew = 30 * time.Second
+
+type Claims struct {
+	Issuer    string
+	Subject   string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore time.Time
+}
+
+// JWTVerifier проверяет access-токены OIDC-провайдера: подпись (RS256/ES256),
+// срок действия, issuer и audience
+type JWTVerifier struct {
+	keys     KeySet
+	issuer   string
+	audience string
+	now      func() time.Time
+}
+
+func NewJWTVerifier(keys KeySet, issuer, audience string) *JWTVerifier {
+	return &JWTVerifier{
+		keys:     keys,
+		issuer:   issuer,
+		audience: audience,
+		now:      time.Now,
+	}
+}
+
+// LooksLikeJWT отличает JWT от API-ключа по формату header.payload.signature
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: bad signature encoding", ErrInvalidToken)
+	}
+
+	key, err := v.keys.Key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifySignature(header.Alg, key, digest[:], signature); err != nil {
+		return nil, err
+	}
+
+	var payload struct {
+		Iss string          `json:"iss"`
+		Sub string          `json:"sub"`
+		Aud json.RawMessage `json:"aud"`
+		Exp *int64          `json:"exp"`
+		Nbf *int64          `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return nil, err
+	}
+
+	audience, err := parseAudience(payload.Aud)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{
+		Issuer:   payload.Iss,
+		Subject:  payload.Sub,
+		Audience: audience,
+	}
+	if payload.Exp == nil {
+		return nil, fmt.Errorf("%w: missing exp", ErrInvalidToken)
+	}
+	claims.ExpiresAt = time.Unix(*payload.Exp, 0)
+	if payload.Nbf != nil {
+		claims.NotBefore = time.Unix(*payload.Nbf, 0)
+	}
+
+	if err := v.validate(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+func (v *JWTVerifier) validate(claims *Claims) error {
+	now := v.now()
+
+	if now.After(claims.ExpiresAt.Add(clockSkew)) {
+		return ErrTokenExpired
+	}
+	if !claims.NotBefore.IsZero() && now.Add(clockSkew).Before(claims.NotBefore) {
+		return fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+	}
+	if claims.Issuer != v.issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if claims.Subject == "" {
+		return fmt.Errorf("%w: missing sub", ErrInvalidToken)
+	}
+
+	for _, aud := range claims.Audience {
+		if aud == v.audience {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+}
+
+// alg из заголовка сверяется с типом ключа, поэтому подменить RS256 на HS256
+// или "none" не получится
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) error {
+	switch alg {
+	case "RS256":
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key does not match alg", ErrInvalidToken)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature); err != nil {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	case "ES256":
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key does not match alg", ErrInvalidToken)
+		}
+		// JWS хранит подпись ECDSA как r||s фиксированной длины, а не в ASN.1
+		if len(signature) != 64 {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("%w: bad signature", ErrInvalidToken)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, alg)
+	}
+	return nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: bad segment encoding", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: bad segment JSON", ErrInvalidToken)
+	}
+	return nil
+}
+
+// aud по спецификации может быть как строкой, так и массивом строк
+func parseAudience(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return []string{single}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, fmt.Errorf("%w: bad aud claim", ErrInvalidToken)
+	}
+	return many, nil
+}
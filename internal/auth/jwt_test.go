@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIssuer   = "https://sso.example.internal"
+	testAudience = "pr-reviewer"
+)
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signingInput := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err := rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		signature = sig
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+
+	return signingInput + "." + b64(signature)
+}
+
+func testJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) KeySet {
+	t.Helper()
+
+	doc, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA", "kid": "rsa-1", "use": "sig",
+				"n": b64(rsaKey.N.Bytes()),
+				"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC", "kid": "ec-1", "crv": "P-256",
+				"x": b64(ecKey.X.FillBytes(make([]byte, 32))),
+				"y": b64(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		},
+	})
+
+	keys, err := ParseJWKS(doc)
+	require.NoError(t, err)
+	return StaticKeySet(keys)
+}
+
+func TestJWTVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	verifier := NewJWTVerifier(testJWKS(t, rsaKey, ecKey), testIssuer, testAudience)
+
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"iss": testIssuer,
+			"sub": "u1",
+			"aud": testAudience,
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+	}
+
+	t.Run("RS256", func(t *testing.T) {
+		claims, err := verifier.Verify(context.Background(), signToken(t, "RS256", "rsa-1", rsaKey, validClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, "u1", claims.Subject)
+	})
+
+	t.Run("ES256 with audience list", func(t *testing.T) {
+		c := validClaims()
+		c["aud"] = []string{"other", testAudience}
+		claims, err := verifier.Verify(context.Background(), signToken(t, "ES256", "ec-1", ecKey, c))
+		require.NoError(t, err)
+		assert.Equal(t, "u1", claims.Subject)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		c := validClaims()
+		c["exp"] = time.Now().Add(-time.Hour).Unix()
+		_, err := verifier.Verify(context.Background(), signToken(t, "RS256", "rsa-1", rsaKey, c))
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("wrong audience", func(t *testing.T) {
+		c := validClaims()
+		c["aud"] = "someone-else"
+		_, err := verifier.Verify(context.Background(), signToken(t, "RS256", "rsa-1", rsaKey, c))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("wrong issuer", func(t *testing.T) {
+		c := validClaims()
+		c["iss"] = "https://evil.example"
+		_, err := verifier.Verify(context.Background(), signToken(t, "RS256", "rsa-1", rsaKey, c))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("signed by unknown key", func(t *testing.T) {
+		_, err := verifier.Verify(context.Background(), signToken(t, "RS256", "rsa-1", otherKey, validClaims()))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("unknown kid", func(t *testing.T) {
+		_, err := verifier.Verify(context.Background(), signToken(t, "RS256", "rsa-2", rsaKey, validClaims()))
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("alg does not match key", func(t *testing.T) {
+		_, err := verifier.Verify(context.Background(), signToken(t, "ES256", "rsa-1", ecKey, validClaims()))
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("alg none", func(t *testing.T) {
+		token := signToken(t, "RS256", "rsa-1", rsaKey, validClaims())
+		parts := strings.Split(token, ".")
+		header, _ := json.Marshal(map[string]string{"alg": "none", "kid": "rsa-1"})
+		_, err := verifier.Verify(context.Background(), b64(header)+"."+parts[1]+".")
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+}
+
+func TestRemoteKeySet(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	doc, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA", "kid": "rsa-1", "use": "sig",
+			"n": b64(rsaKey.N.Bytes()),
+			"e": b64(big.NewInt(int64(rsaKey.E)).Bytes()),
+		}},
+	})
+
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// первое скачивание отдаётся сразу, остальные ждут release
+		if fetches.Add(1) > 1 {
+			<-release
+		}
+		w.Write(doc)
+	}))
+	defer server.Close()
+
+	keys := NewRemoteKeySet(server.URL)
+	ctx := context.Background()
+
+	_, err = keys.Key(ctx, "rsa-1")
+	require.NoError(t, err)
+	// как будто minRefresh уже прошёл
+	keys.fetchedAt = time.Time{}
+
+	// незнакомый kid запускает повторное скачивание, которое висит на медленном провайдере
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(ctx, "rsa-2")
+			assert.ErrorIs(t, err, ErrUnknownKey)
+		}()
+	}
+	require.Eventually(t, func() bool { return fetches.Load() == 2 }, time.Second, time.Millisecond)
+
+	// известный kid отдаётся из кэша, не дожидаясь скачивания
+	done := make(chan error, 1)
+	go func() {
+		_, err := keys.Key(ctx, "rsa-1")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("cached key lookup blocked by JWKS refresh")
+	}
+
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(2), fetches.Load(), "concurrent refreshes must share one fetch")
+}
@@ -0,0 +1,215 @@
+package events
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypePRCreated          = "pr.created"
+	TypeReviewerAssigned   = "reviewer.assigned"
+	TypeReviewerReassigned = "reviewer.reassigned"
+	TypePRMerged           = "pr.merged"
+	TypePRClosed           = "pr.closed"
+	TypeReviewSubmitted    = "review.submitted"
+	TypeUserActivity       = "user.activity_changed"
+)
+
+// Event - живое событие для подписчиков. TeamName и UserIDs нужны для фильтрации:
+// команда, к которой относится событие, и все затронутые пользователи
+type Event struct {
+	ID            uint64      `json:"id"`
+	Type          string      `json:"type"`
+	TeamName      string      `json:"team_name,omitempty"`
+	PullRequestID string      `json:"pull_request_id,omitempty"`
+	UserIDs       []string    `json:"user_ids,omitempty"`
+	Data          interface{} `json:"data,omitempty"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+// Filter: пустые поля не фильтруют
+type Filter struct {
+	TeamName string
+	UserID   string
+}
+
+func (f Filter) Match(e Event) bool {
+	if f.TeamName != "" && f.TeamName != e.TeamName {
+		return false
+	}
+	if f.UserID == "" {
+		return true
+	}
+	for _, id := range e.UserIDs {
+		if id == f.UserID {
+			return true
+		}
+	}
+	return false
+}
+
+// Cursor - позиция клиента в потоке (SSE id: "<epoch>-<id>"). Номера событий начинаются
+// заново при каждом запуске брокера, поэтому эпоха отличает ID из прошлого запуска
+type Cursor struct {
+	Epoch string
+	ID    uint64
+}
+
+func (c Cursor) String() string {
+	return c.Epoch + "-" + strconv.FormatUint(c.ID, 10)
+}
+
+// ParseCursor разбирает Last-Event-ID. Голое число - ID без эпохи от старых версий
+// сервиса: такой курсор не совпадёт с текущей эпохой и клиент получит reset
+func ParseCursor(raw string) (Cursor, error) {
+	epoch, id, found := strings.Cut(raw, "-")
+	if !found {
+		epoch, id = "", raw
+	}
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return Cursor{}, fmt.Errorf("invalid event id %q", raw)
+	}
+	return Cursor{Epoch: epoch, ID: n}, nil
+}
+
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+}
+
+// Broker рассылает события подписчикам внутри процесса. Publish никогда не
+// блокируется: подписчик, который не успевает вычитывать свой буфер, отключается
+// (канал закрывается) и может переподключиться с Last-Event-ID.
+// Последние replaySize событий хранятся для такого переподключения
+type Broker struct {
+	replaySize   int
+	clientBuffer int
+	heartbeat    time.Duration
+	epoch        string
+
+	mu     sync.Mutex
+	lastID uint64
+	replay []Event
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+// DefaultHeartbeat - интервал пинга подписчиков, если он не задан
+const DefaultHeartbeat = 15 * time.Second
+
+// NewBroker: heartbeat - как часто потоки подписчиков пингуются, чтобы прокси
+// не закрывали простаивающее соединение
+func NewBroker(replaySize, clientBuffer int, heartbeat time.Duration) *Broker {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	return &Broker{
+		replaySize:   replaySize,
+		clientBuffer: clientBuffer,
+		heartbeat:    heartbeat,
+		epoch:        strconv.FormatInt(time.Now().UnixNano(), 36),
+		subs:         make(map[*Subscription]struct{}),
+	}
+}
+
+// Epoch отличает этот запуск брокера от предыдущих
+func (b *Broker) Epoch() string {
+	return b.epoch
+}
+
+func (b *Broker) Heartbeat() time.Duration {
+	return b.heartbeat
+}
+
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	e.ID = b.lastID
+	if e.CreatedAt.IsZero() {
+		e.CreatedAt = time.Now()
+	}
+
+	b.replay = append(b.replay, e)
+	if len(b.replay) > b.replaySize {
+		b.replay = b.replay[len(b.replay)-b.replaySize:]
+	}
+
+	for sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Subscribe регистрирует подписчика и возвращает пропущенные после курсора события
+// (нулевой курсор - без досылки). complete=false означает, что часть пропущенных событий
+// уже вытеснена из буфера (или курсор из прошлого запуска сервиса) и клиенту стоит
+// перечитать состояние целиком
+func (b *Broker) Subscribe(filter Filter, after Cursor) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, b.clientBuffer)
+	sub = &Subscription{C: ch, ch: ch, filter: filter}
+	// после Close подписка сразу закрыта: клиент переподключится к другому экземпляру
+	if b.closed {
+		close(ch)
+		return sub, nil, true
+	}
+	b.subs[sub] = struct{}{}
+
+	if after == (Cursor{}) {
+		return sub, nil, true
+	}
+	if after.Epoch != b.epoch {
+		return sub, nil, false
+	}
+
+	lastID := after.ID
+	complete = lastID <= b.lastID
+	if len(b.replay) > 0 && b.replay[0].ID > lastID+1 {
+		complete = false
+	}
+	for _, e := range b.replay {
+		if e.ID > lastID && filter.Match(e) {
+			missed = append(missed, e)
+		}
+	}
+	return sub, missed, complete
+}
+
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Close закрывает каналы всех подписчиков при остановке сервиса, чтобы долгие
+// SSE-соединения завершились и не задерживали http.Server.Shutdown
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
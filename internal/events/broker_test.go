@@ -0,0 +1,100 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerFilter(t *testing.T) {
+	b := NewBroker(10, 10, 0)
+	sub, _, _ := b.Subscribe(Filter{TeamName: "backend", UserID: "u2"}, Cursor{})
+
+	b.Publish(Event{Type: TypePRCreated, TeamName: "frontend", UserIDs: []string{"u2"}})
+	b.Publish(Event{Type: TypePRCreated, TeamName: "backend", UserIDs: []string{"u1"}})
+	b.Publish(Event{Type: TypeReviewerAssigned, TeamName: "backend", UserIDs: []string{"u2"}})
+
+	require.Len(t, sub.C, 1)
+	e := <-sub.C
+	assert.Equal(t, TypeReviewerAssigned, e.Type)
+	assert.Equal(t, uint64(3), e.ID)
+}
+
+func TestBrokerReplay(t *testing.T) {
+	b := NewBroker(3, 10, 0)
+	for i := 0; i < 5; i++ {
+		b.Publish(Event{Type: TypePRMerged})
+	}
+
+	t.Run("within buffer", func(t *testing.T) {
+		_, missed, complete := b.Subscribe(Filter{}, Cursor{Epoch: b.Epoch(), ID: 3})
+		assert.True(t, complete)
+		require.Len(t, missed, 2)
+		assert.Equal(t, uint64(4), missed[0].ID)
+	})
+
+	t.Run("evicted from buffer", func(t *testing.T) {
+		_, missed, complete := b.Subscribe(Filter{}, Cursor{Epoch: b.Epoch(), ID: 1})
+		assert.False(t, complete)
+		assert.Len(t, missed, 3)
+	})
+
+	t.Run("id ahead of broker", func(t *testing.T) {
+		_, missed, complete := b.Subscribe(Filter{}, Cursor{Epoch: b.Epoch(), ID: 42})
+		assert.False(t, complete)
+		assert.Empty(t, missed)
+	})
+
+	t.Run("id from previous run", func(t *testing.T) {
+		_, missed, complete := b.Subscribe(Filter{}, Cursor{Epoch: "previous", ID: 4})
+		assert.False(t, complete, "ID прошлого запуска совпадает по номеру, но не по эпохе")
+		assert.Empty(t, missed)
+	})
+
+}
+
+func TestBrokerDropsSlowSubscriber(t *testing.T) {
+	b := NewBroker(10, 1, 0)
+	slow, _, _ := b.Subscribe(Filter{}, Cursor{})
+
+	b.Publish(Event{Type: TypePRCreated})
+	b.Publish(Event{Type: TypePRMerged})
+
+	<-slow.C
+	_, ok := <-slow.C
+	assert.False(t, ok, "отстающий подписчик отключается, а не блокирует Publish")
+
+	// повторная отписка уже отключённого клиента безопасна
+	b.Unsubscribe(slow)
+}
+
+func TestBrokerClose(t *testing.T) {
+	b := NewBroker(10, 10, 0)
+	sub, _, _ := b.Subscribe(Filter{}, Cursor{})
+
+	b.Close()
+	_, ok := <-sub.C
+	assert.False(t, ok)
+
+	late, _, _ := b.Subscribe(Filter{}, Cursor{})
+	_, ok = <-late.C
+	assert.False(t, ok)
+
+	b.Unsubscribe(sub)
+	b.Publish(Event{Type: TypePRMerged})
+}
+
+func TestParseCursor(t *testing.T) {
+	c, err := ParseCursor("m1x2-17")
+	require.NoError(t, err)
+	assert.Equal(t, Cursor{Epoch: "m1x2", ID: 17}, c)
+	assert.Equal(t, "m1x2-17", c.String())
+
+	c, err = ParseCursor("17")
+	require.NoError(t, err)
+	assert.Equal(t, Cursor{ID: 17}, c)
+
+	_, err = ParseCursor("m1x2-abc")
+	assert.Error(t, err)
+}
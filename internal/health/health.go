@@ -0,0 +1,116 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkTimeout ограничивает каждую проверку зависимостей в /readyz
+const checkTimeout = 2 * time.Second
+
+// CheckFunc проверяет доступность зависимости, например db.PingContext
+type CheckFunc func(ctx context.Context) error
+
+type namedCheck struct {
+	name string
+	fn   CheckFunc
+}
+
+// Checker отвечает на /livez и /readyz. Готовность - все проверки зависимостей прошли
+// и сервис не останавливается; состояние фоновых воркеров только сообщается
+type Checker struct {
+	mu       sync.Mutex
+	checks   []namedCheck
+	workers  []*Worker
+	draining atomic.Bool
+}
+
+func New() *Checker {
+	return &Checker{}
+}
+
+func (c *Checker) AddCheck(name string, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, fn: fn})
+}
+
+// NewWorker регистрирует фоновый воркер, который запускается через Worker.Run
+func (c *Checker) NewWorker(name string, interval time.Duration) *Worker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w := &Worker{name: name, interval: interval, done: make(chan struct{})}
+	c.workers = append(c.workers, w)
+	return w
+}
+
+// Drain переводит /readyz в 503, чтобы балансировщик перестал слать новые запросы,
+// пока обрабатываются текущие
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Livez - процесс жив и обслуживает HTTP; зависимости не проверяются, чтобы
+// недоступная база не приводила к перезапуску всех экземпляров
+func (c *Checker) Livez(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type readiness struct {
+	Status  string                  `json:"status"`
+	Checks  map[string]string       `json:"checks"`
+	Workers map[string]WorkerStatus `json:"workers,omitempty"`
+}
+
+func (c *Checker) Readyz(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	checks := append([]namedCheck(nil), c.checks...)
+	workers := append([]*Worker(nil), c.workers...)
+	c.mu.Unlock()
+
+	report := readiness{Status: "ready", Checks: map[string]string{}}
+	if c.draining.Load() {
+		report.Status = "draining"
+	}
+
+	// текст ошибки зависимости остаётся в логе: /readyz доступен без авторизации
+	for _, check := range checks {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		err := check.fn(ctx)
+		cancel()
+		if err != nil {
+			slog.WarnContext(r.Context(), "readiness check failed", "check", check.name, "error", err)
+			report.Checks[check.name] = "unavailable"
+			if report.Status == "ready" {
+				report.Status = "not_ready"
+			}
+			continue
+		}
+		report.Checks[check.name] = "ok"
+	}
+
+	if len(workers) > 0 {
+		report.Workers = make(map[string]WorkerStatus, len(workers))
+		for _, worker := range workers {
+			report.Workers[worker.name] = worker.Status()
+		}
+	}
+
+	status := http.StatusOK
+	if report.Status != "ready" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, report)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readyz(t *testing.T, c *Checker) (int, readiness) {
+	rec := httptest.NewRecorder()
+	c.Readyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	var report readiness
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+func TestReadyz(t *testing.T) {
+	dbErr := error(nil)
+	c := New()
+	c.AddCheck("database", func(ctx context.Context) error { return dbErr })
+
+	code, report := readyz(t, c)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", report.Checks["database"])
+
+	dbErr = errors.New("connection refused")
+	code, report = readyz(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "not_ready", report.Status)
+	assert.Equal(t, "unavailable", report.Checks["database"])
+
+	dbErr = nil
+	c.Drain()
+	code, report = readyz(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "draining", report.Status)
+}
+
+func TestWorker(t *testing.T) {
+	c := New()
+	worker := c.NewWorker("purger", 5*time.Millisecond)
+	assert.Equal(t, WorkerPending, worker.Status().Status)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	runs := make(chan struct{}, 10)
+	go worker.Run(ctx, func(ctx context.Context) error {
+		runs <- struct{}{}
+		return errors.New("purge failed")
+	})
+	<-runs
+	<-runs
+
+	_, report := readyz(t, c)
+	assert.Equal(t, WorkerRunning, report.Workers["purger"].Status)
+	assert.Equal(t, "failed", report.Workers["purger"].LastError, "текст ошибки остаётся в логе")
+
+	cancel()
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), time.Second)
+	defer cancelWait()
+	require.NoError(t, worker.Wait(waitCtx))
+	assert.Equal(t, WorkerStopped, worker.Status().Status)
+}
@@ -0,0 +1,112 @@
+package health
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Состояния фонового воркера в /readyz
+const (
+	WorkerPending = "pending"
+	WorkerRunning = "running"
+	WorkerStalled = "stalled"
+	WorkerStopped = "stopped"
+)
+
+// workerFailed - last_error упавшего запуска; сам текст ошибки остаётся в логе,
+// потому что /readyz доступен без авторизации
+const workerFailed = "failed"
+
+// WorkerStatus - состояние воркера: последний запуск и признак его ошибки
+type WorkerStatus struct {
+	Status    string     `json:"status"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// Worker периодически выполняет задачу и запоминает результат для /readyz
+type Worker struct {
+	name     string
+	interval time.Duration
+	done     chan struct{}
+
+	mu        sync.Mutex
+	started   bool
+	stopped   bool
+	lastRun   time.Time
+	lastError string
+}
+
+// Run выполняет fn каждые interval, пока не отменён ctx. Начатый запуск
+// доводится до конца: ctx отменяется только между запусками
+func (w *Worker) Run(ctx context.Context, fn func(ctx context.Context) error) {
+	w.mu.Lock()
+	w.started = true
+	w.mu.Unlock()
+
+	defer func() {
+		w.mu.Lock()
+		w.stopped = true
+		w.mu.Unlock()
+		close(w.done)
+	}()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := fn(context.WithoutCancel(ctx))
+			if err != nil {
+				slog.ErrorContext(ctx, "background worker failed", "worker", w.name, "error", err)
+			}
+
+			w.mu.Lock()
+			w.lastRun = time.Now()
+			w.lastError = ""
+			if err != nil {
+				w.lastError = workerFailed
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// Wait ждёт завершения Run после отмены его контекста
+func (w *Worker) Wait(ctx context.Context) error {
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status: stalled - воркер не отработал дольше трёх интервалов
+func (w *Worker) Status() WorkerStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := WorkerStatus{LastError: w.lastError}
+	if !w.lastRun.IsZero() {
+		lastRun := w.lastRun
+		status.LastRun = &lastRun
+	}
+
+	switch {
+	case w.stopped:
+		status.Status = WorkerStopped
+	case !w.started:
+		status.Status = WorkerPending
+	case !w.lastRun.IsZero() && time.Since(w.lastRun) > 3*w.interval:
+		status.Status = WorkerStalled
+	default:
+		status.Status = WorkerRunning
+	}
+	return status
+}
@@ -0,0 +1,29 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/requestid"
+)
+
+func TestRequestIDAttached(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo).With("component", "test")
+
+	logger.ErrorContext(requestid.NewContext(context.Background(), "req-1"), "query failed", "error", "boom")
+	logger.DebugContext(context.Background(), "below level")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	assert.Equal(t, "req-1", entry["request_id"])
+	assert.Equal(t, "test", entry["component"])
+	assert.Equal(t, "query failed", entry["msg"])
+	assert.NotContains(t, entry, "trace_id")
+}
@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+const namespace = "pr_reviewer"
+
+// workloadTimeout ограничивает запрос нагрузки команд во время сбора метрик
+const workloadTimeout = 5 * time.Second
+
+// WorkloadSource отдаёт текущую нагрузку команд; опрашивается при каждом сборе метрик
+type WorkloadSource interface {
+	GetTeamWorkloads(ctx context.Context) ([]models.TeamWorkload, error)
+}
+
+// Metrics - коллекторы сервиса в собственном реестре, отдаются на /metrics
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+	dbDuration   *prometheus.HistogramVec
+	prChanges    *prometheus.CounterVec
+	noCandidate  *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern, method and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		dbDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "db_query_duration_seconds",
+			Help:      "Repository call latency by method.",
+			Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}, []string{"method"}),
+		prChanges: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "pull_request_changes_total",
+			Help:      "Pull request changes: created, merged, reassigned.",
+		}, []string{"action"}),
+		noCandidate: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "no_candidate_total",
+			Help:      "Reassignments that found no active replacement reviewer, by cause.",
+		}, []string{"cause"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration, m.dbDuration, m.prChanges, m.noCandidate,
+	)
+	return m
+}
+
+// Handler отдаёт метрики в текстовом формате Prometheus
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// PRChanged считает изменение PR: created, merged или reassigned
+func (m *Metrics) PRChanged(action string) {
+	m.prChanges.WithLabelValues(action).Inc()
+}
+
+// NoCandidate считает переназначение, для которого не нашлось активного ревьювера
+func (m *Metrics) NoCandidate(cause models.AssignmentCause) {
+	m.noCandidate.WithLabelValues(string(cause)).Inc()
+}
+
+// RegisterWorkload добавляет gauges открытых PR и активных ревьюверов по командам
+func (m *Metrics) RegisterWorkload(source WorkloadSource) {
+	m.registry.MustRegister(&workloadCollector{
+		source: source,
+		openPRs: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "open_pull_requests"),
+			"Open pull requests by author team.", []string{"team"}, nil),
+		activeReviewers: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "active_reviewers"),
+			"Active team members available as reviewers.", []string{"team"}, nil),
+	})
+}
+
+// workloadCollector читает нагрузку из базы в момент сбора, поэтому значения
+// не расходятся с данными и не требуют обновления при каждом изменении
+type workloadCollector struct {
+	source          WorkloadSource
+	openPRs         *prometheus.Desc
+	activeReviewers *prometheus.Desc
+}
+
+func (c *workloadCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.openPRs
+	ch <- c.activeReviewers
+}
+
+func (c *workloadCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), workloadTimeout)
+	defer cancel()
+
+	workloads, err := c.source.GetTeamWorkloads(ctx)
+	if err != nil {
+		slog.Error("failed to collect team workloads", "error", err)
+		ch <- prometheus.NewInvalidMetric(c.openPRs, err)
+		return
+	}
+	for _, w := range workloads {
+		ch <- prometheus.MustNewConstMetric(c.openPRs, prometheus.GaugeValue, float64(w.OpenPRs), w.TeamName)
+		ch <- prometheus.MustNewConstMetric(c.activeReviewers, prometheus.GaugeValue, float64(w.ActiveReviewers), w.TeamName)
+	}
+}
@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// RepositoryHook замеряет db_query_duration_seconds по методам репозитория (см. instrumented.Hook)
+func (m *Metrics) RepositoryHook(ctx context.Context, method string, _ []attribute.KeyValue) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(error) {
+		m.dbDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+	}
+}
@@ -1,13 +1,15 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
 type ErrorResponse struct {
 	Error struct {
-		Code    string `json:"code"`
-		Message string `json:"message"`
+		Code      string `json:"code"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id,omitempty"`
 	} `json:"error"`
 }
 
@@ -18,15 +20,41 @@ type User struct {
 	IsActive bool   `json:"is_active" db:"is_active"`
 }
 
+// UserFilter - выборка справочника пользователей; курсор - последний выданный user_id
+type UserFilter struct {
+	TeamName string
+	IsActive *bool
+	AfterID  string
+	Limit    int
+}
+
+// UpdateUserRequest: nil-поля не меняются
+type UpdateUserRequest struct {
+	UserID   string  `json:"user_id"`
+	Username *string `json:"username"`
+	TeamName *string `json:"team_name"`
+	IsActive *bool   `json:"is_active"`
+}
+
 type TeamMember struct {
 	UserID   string `json:"user_id" db:"user_id"`
 	Username string `json:"username" db:"username"`
 	IsActive bool   `json:"is_active" db:"is_active"`
 }
 
+// Team.Version растёт при любом изменении команды или её участников и служит для ETag
 type Team struct {
-	TeamName string       `json:"team_name"`
-	Members  []TeamMember `json:"members"`
+	TeamName   string       `json:"team_name"`
+	ParentTeam string       `json:"parent_team,omitempty"`
+	Members    []TeamMember `json:"members"`
+	Version    int64        `json:"-"`
+}
+
+// TeamNode - узел иерархии команд (org -> department -> team)
+type TeamNode struct {
+	TeamName   string      `json:"team_name" db:"team_name"`
+	ParentTeam string      `json:"parent_team,omitempty" db:"parent_team"`
+	Children   []*TeamNode `json:"children"`
 }
 
 type PullRequestStatus string
@@ -34,8 +62,11 @@ type PullRequestStatus string
 const (
 	StatusOpen   PullRequestStatus = "OPEN"
 	StatusMerged PullRequestStatus = "MERGED"
+	// StatusClosed - PR закрыт без мержа
+	StatusClosed PullRequestStatus = "CLOSED"
 )
 
+// PullRequest.Version растёт при каждом изменении PR и служит для ETag и If-Match
 type PullRequest struct {
 	PullRequestID     string            `json:"pull_request_id" db:"pull_request_id"`
 	PullRequestName   string            `json:"pull_request_name" db:"pull_request_name"`
@@ -44,6 +75,67 @@ type PullRequest struct {
 	AssignedReviewers []string          `json:"assigned_reviewers"`
 	CreatedAt         *time.Time        `json:"createdAt,omitempty" db:"created_at"`
 	MergedAt          *time.Time        `json:"mergedAt,omitempty" db:"merged_at"`
+	Version           int64             `json:"-" db:"version"`
+}
+
+type PREventType string
+
+const (
+	PREventCreated            PREventType = "created"
+	PREventReviewerAssigned   PREventType = "reviewer_assigned"
+	PREventReviewerUnassigned PREventType = "reviewer_unassigned"
+	PREventReviewSubmitted    PREventType = "review_submitted"
+	PREventMerged             PREventType = "merged"
+	PREventClosed             PREventType = "closed"
+)
+
+// AssignmentCause - почему ревьювер был назначен или снят
+type AssignmentCause string
+
+const (
+	CauseAutoAssign       AssignmentCause = "auto_assign"
+	CauseManualReassign   AssignmentCause = "manual_reassign"
+	CauseBulkDeactivation AssignmentCause = "bulk_deactivation"
+	// CauseEscalation - ревьювер заменён, потому что не отвечает по PR
+	CauseEscalation AssignmentCause = "escalation"
+	CauseBackfill   AssignmentCause = "backfill"
+)
+
+// ReviewVerdict - решение ревьювера по PR
+type ReviewVerdict string
+
+const (
+	VerdictApproved         ReviewVerdict = "approved"
+	VerdictChangesRequested ReviewVerdict = "changes_requested"
+	VerdictCommented        ReviewVerdict = "commented"
+)
+
+func (v ReviewVerdict) Valid() bool {
+	switch v {
+	case VerdictApproved, VerdictChangesRequested, VerdictCommented:
+		return true
+	}
+	return false
+}
+
+// PREvent - событие истории PR; UserID и Cause заполнены для событий назначения,
+// UserID и Verdict - для review_submitted
+type PREvent struct {
+	ID            int64           `json:"id" db:"id"`
+	PullRequestID string          `json:"pull_request_id" db:"pull_request_id"`
+	EventType     PREventType     `json:"event_type" db:"event_type"`
+	UserID        string          `json:"user_id,omitempty" db:"user_id"`
+	Cause         AssignmentCause `json:"cause,omitempty" db:"cause"`
+	Verdict       ReviewVerdict   `json:"verdict,omitempty" db:"verdict"`
+	Actor         string          `json:"actor" db:"actor"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// PREventFilter - выборка истории PR: пустые поля не фильтруют, TeamName - команда автора PR
+type PREventFilter struct {
+	TimeWindow
+	TeamName   string
+	EventTypes []string
 }
 
 type PullRequestShort struct {
@@ -51,12 +143,193 @@ type PullRequestShort struct {
 	PullRequestName string            `json:"pull_request_name" db:"pull_request_name"`
 	AuthorID        string            `json:"author_id" db:"author_id"`
 	Status          PullRequestStatus `json:"status" db:"status"`
+	CreatedAt       *time.Time        `json:"createdAt,omitempty" db:"created_at"`
+}
+
+// PRCursor - позиция keyset-пагинации PR: последняя выданная пара (created_at, pull_request_id)
+type PRCursor struct {
+	CreatedAt     time.Time
+	PullRequestID string
+}
+
+// ReviewFilter - выборка PR ревьювера: пустые поля не фильтруют, Limit 0 - без ограничения.
+// По умолчанию сначала новые, Ascending - сначала старые
+type ReviewFilter struct {
+	Status        PullRequestStatus
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	Ascending     bool
+	After         *PRCursor
+	Limit         int
 }
 
+// ReviewStat считается по истории назначений за период: ReviewCount - ревью, открытые
+// на конец периода, остальные счётчики - события внутри периода
 type ReviewStat struct {
-	UserID      string `json:"user_id" db:"user_id"`
-	Username    string `json:"username" db:"username"`
-	ReviewCount int    `json:"review_count" db:"review_count"`
+	UserID           string `json:"user_id" db:"user_id"`
+	Username         string `json:"username" db:"username"`
+	ReviewCount      int    `json:"review_count" db:"review_count"`
+	ReviewsAssigned  int    `json:"reviews_assigned" db:"reviews_assigned"`
+	ReviewsCompleted int    `json:"reviews_completed" db:"reviews_completed"`
+	PRsAuthored      int    `json:"prs_authored" db:"prs_authored"`
+	ReassignedAway   int    `json:"reassigned_away" db:"reassigned_away"`
+}
+
+// TeamReviewStat - открытые ревью по узлу иерархии:
+// ReviewCount - только участники самой команды, TotalReviewCount - вместе с поддеревом
+type TeamReviewStat struct {
+	TeamName         string `json:"team_name" db:"team_name"`
+	ParentTeam       string `json:"parent_team,omitempty" db:"parent_team"`
+	ReviewCount      int    `json:"review_count" db:"review_count"`
+	TotalReviewCount int    `json:"total_review_count" db:"-"`
+}
+
+// TeamWorkload - открытые PR авторов команды и число её активных участников
+type TeamWorkload struct {
+	TeamName        string `db:"team_name"`
+	OpenPRs         int    `db:"open_prs"`
+	ActiveReviewers int    `db:"active_reviewers"`
+}
+
+// PRListFilter - листинг PR: к общим фильтрам ReviewFilter добавляются автор, команда автора,
+// ревьювер, период мержа и подстрока в названии
+type PRListFilter struct {
+	ReviewFilter
+	AuthorID     string
+	TeamName     string
+	ReviewerID   string
+	MergedAfter  *time.Time
+	MergedBefore *time.Time
+	Query        string
+}
+
+// StatCursor - позиция в статистике, отсортированной по (review_count DESC, user_id)
+type StatCursor struct {
+	ReviewCount int
+	UserID      string
+}
+
+// TimeWindow - полуинтервал [From, To); nil-граница не ограничивает,
+// пустой To - период до текущего момента
+type TimeWindow struct {
+	From *time.Time
+	To   *time.Time
+}
+
+// ReviewStatsFilter: Cursor - непрозрачный курсор из next_cursor предыдущей страницы
+type ReviewStatsFilter struct {
+	TimeWindow
+	TeamName string
+	Subtree  bool
+	Cursor   string
+	Limit    int
+}
+
+const (
+	FairnessOver  = "over"
+	FairnessUnder = "under"
+)
+
+// FairnessMember - доля участника в назначениях за период; Deviation - относительное
+// отклонение от равной доли (0.3 - на 30% больше), Flag - отклонение больше порога
+type FairnessMember struct {
+	ReviewStat
+	IsActive  bool    `json:"is_active"`
+	Share     float64 `json:"share"`
+	Deviation float64 `json:"deviation"`
+	Flag      string  `json:"flag,omitempty"`
+}
+
+// FairnessFilter: Threshold 0 - порог по умолчанию из настроек сервиса
+type FairnessFilter struct {
+	TimeWindow
+	TeamName  string
+	Threshold float64
+}
+
+// FairnessReport - распределение назначений внутри команды. MaxMinRatio nil,
+// если кто-то из участников не получил ни одного назначения
+type FairnessReport struct {
+	TeamName         string           `json:"team_name"`
+	TotalAssignments int              `json:"total_assignments"`
+	EqualShare       float64          `json:"equal_share"`
+	Gini             float64          `json:"gini"`
+	MaxMinRatio      *float64         `json:"max_min_ratio"`
+	Threshold        float64          `json:"threshold"`
+	Members          []FairnessMember `json:"members"`
+}
+
+// PairFilter - назначения по парам автор -> ревьювер: пустые поля не фильтруют,
+// TeamName - команда автора
+type PairFilter struct {
+	TimeWindow
+	TeamName string
+	AuthorID string
+}
+
+// ReviewPair - сколько раз ревьювера назначали на PR автора; AuthorShare - доля пары
+// среди всех назначений на PR автора, близкая к 1 указывает на замкнутую пару
+type ReviewPair struct {
+	AuthorID    string  `json:"author_id" db:"author_id"`
+	ReviewerID  string  `json:"reviewer_id" db:"reviewer_id"`
+	Count       int     `json:"count" db:"count"`
+	AuthorShare float64 `json:"author_share" db:"-"`
+}
+
+// Метрики и группировки GET /stats/turnaround
+const (
+	TurnaroundFirstReview = "time_to_first_review"
+	TurnaroundApproval    = "time_to_approval"
+	TurnaroundMerge       = "time_to_merge"
+
+	TurnaroundByTeam     = "team"
+	TurnaroundByReviewer = "reviewer"
+
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// TurnaroundFilter: период и бакеты считаются по моменту завершения замера (ревью,
+// одобрения или мержа), пустой Bucket - без временного ряда
+type TurnaroundFilter struct {
+	TimeWindow
+	GroupBy  string
+	TeamName string
+	Bucket   string
+}
+
+// DurationStats - медиана и 90-й перцентиль длительностей в секундах
+type DurationStats struct {
+	Count         int     `json:"count" db:"count"`
+	MedianSeconds float64 `json:"median_seconds" db:"median_seconds"`
+	P90Seconds    float64 `json:"p90_seconds" db:"p90_seconds"`
+}
+
+// TurnaroundRow - агрегат одной метрики группы; BucketStart nil - итог за весь период
+type TurnaroundRow struct {
+	GroupKey    string     `db:"group_key"`
+	Metric      string     `db:"metric"`
+	BucketStart *time.Time `db:"bucket_start"`
+	DurationStats
+}
+
+// TurnaroundMetrics - метрики группы или бакета; nil - замеров не было
+type TurnaroundMetrics struct {
+	TimeToFirstReview *DurationStats `json:"time_to_first_review,omitempty"`
+	TimeToApproval    *DurationStats `json:"time_to_approval,omitempty"`
+	TimeToMerge       *DurationStats `json:"time_to_merge,omitempty"`
+}
+
+type TurnaroundBucket struct {
+	Start time.Time `json:"start"`
+	TurnaroundMetrics
+}
+
+// TurnaroundGroup - метрики команды или ревьювера (Key) за период и, если запрошен, ряд по бакетам
+type TurnaroundGroup struct {
+	Key string `json:"key"`
+	TurnaroundMetrics
+	Series []TurnaroundBucket `json:"series,omitempty"`
 }
 
 type BulkDeactivateRequest struct {
@@ -67,3 +340,65 @@ type BulkDeactivateResponse struct {
 	Message     string   `json:"message"`
 	Deactivated []string `json:"deactivated_users"`
 }
+
+// APIKey - ключ доступа; сам секрет не хранится, только его хеш
+type APIKey struct {
+	KeyID      string     `json:"key_id" db:"key_id"`
+	Name       string     `json:"name" db:"name"`
+	Role       string     `json:"role" db:"role"`
+	Scopes     []string   `json:"scopes" db:"-"`
+	UserID     string     `json:"user_id,omitempty" db:"user_id"`
+	TeamName   string     `json:"team_name,omitempty" db:"team_name"`
+	CreatedAt  *time.Time `json:"created_at,omitempty" db:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name"`
+	Role      string     `json:"role"`
+	Scopes    []string   `json:"scopes"`
+	UserID    string     `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// AuditEntry - запись журнала изменений; Before/After - состояние объекта до и после операции
+type AuditEntry struct {
+	ID         int64           `json:"id" db:"id"`
+	Actor      string          `json:"actor" db:"actor"`
+	Action     string          `json:"action" db:"action"`
+	TargetType string          `json:"target_type" db:"target_type"`
+	TargetID   string          `json:"target_id" db:"target_id"`
+	Before     json.RawMessage `json:"before,omitempty" db:"-"`
+	After      json.RawMessage `json:"after,omitempty" db:"-"`
+	RequestID  string          `json:"request_id,omitempty" db:"request_id"`
+	CreatedAt  time.Time       `json:"created_at" db:"created_at"`
+}
+
+// AuditFilter: пустые поля не фильтруют; BeforeID - курсор (записи с id меньше него)
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	TargetType string
+	TargetID   string
+	From       *time.Time
+	To         *time.Time
+	BeforeID   int64
+	Limit      int
+}
+
+// IdempotencyRecord - сохранённый ответ на запрос с Idempotency-Key.
+// StatusCode 0 - первый запрос с этим ключом ещё выполняется
+type IdempotencyRecord struct {
+	Scope        string `db:"scope"`
+	Key          string `db:"idempotency_key"`
+	RequestHash  string `db:"request_hash"`
+	StatusCode   int    `db:"status_code"`
+	ContentType  string `db:"content_type"`
+	ResponseBody []byte `db:"response_body"`
+	// ResponseHeaders - заголовки ответа, которые отдаются при повторе (например, ETag)
+	ResponseHeaders map[string]string `db:"-"`
+	CreatedAt       time.Time         `db:"created_at"`
+	ExpiresAt       time.Time         `db:"expires_at"`
+}
@@ -0,0 +1,321 @@
+package instrumented
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/repository"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+// Hook вызывается перед каждым методом репозитория и возвращает контекст вызова
+// и функцию, которую decorator вызовет с ошибкой метода после его завершения
+type Hook func(ctx context.Context, method string, attrs []attribute.KeyValue) (context.Context, func(error))
+
+// Repository - обёртка над репозиторием, через которую метрики и трейсинг видят каждый вызов.
+// Для Stream* в вызов входит и обработка строк вызывающим, так как чтение идёт вперемешку с ней
+type Repository struct {
+	next  repository.Repository
+	hooks []Hook
+}
+
+func New(next repository.Repository, hooks ...Hook) *Repository {
+	return &Repository{next: next, hooks: hooks}
+}
+
+func (r *Repository) begin(ctx context.Context, method string, attrs ...attribute.KeyValue) (context.Context, func(error)) {
+	dones := make([]func(error), 0, len(r.hooks))
+	for _, hook := range r.hooks {
+		var done func(error)
+		ctx, done = hook(ctx, method, attrs)
+		dones = append(dones, done)
+	}
+	return ctx, func(err error) {
+		// в обратном порядке, как вложенные defer
+		for i := len(dones) - 1; i >= 0; i-- {
+			dones[i](err)
+		}
+	}
+}
+
+// WithTx оборачивает и репозиторий транзакции, чтобы её запросы тоже попадали в метрики и трейсы
+func (r *Repository) WithTx(ctx context.Context, fn func(tx repository.Repository) error) (err error) {
+	ctx, done := r.begin(ctx, "WithTx")
+	defer func() { done(err) }()
+	return r.next.WithTx(ctx, func(tx repository.Repository) error {
+		return fn(&Repository{next: tx, hooks: r.hooks})
+	})
+}
+
+func (r *Repository) CreateTeam(ctx context.Context, team *models.Team) (err error) {
+	ctx, done := r.begin(ctx, "CreateTeam")
+	defer func() { done(err) }()
+	return r.next.CreateTeam(ctx, team)
+}
+
+func (r *Repository) GetTeam(ctx context.Context, teamName string) (_ *models.Team, err error) {
+	ctx, done := r.begin(ctx, "GetTeam", tracing.Team(teamName))
+	defer func() { done(err) }()
+	return r.next.GetTeam(ctx, teamName)
+}
+
+func (r *Repository) TeamExists(ctx context.Context, teamName string) (_ bool, err error) {
+	ctx, done := r.begin(ctx, "TeamExists", tracing.Team(teamName))
+	defer func() { done(err) }()
+	return r.next.TeamExists(ctx, teamName)
+}
+
+func (r *Repository) SetTeamParent(ctx context.Context, teamName, parentTeam string) (err error) {
+	ctx, done := r.begin(ctx, "SetTeamParent", tracing.Team(teamName))
+	defer func() { done(err) }()
+	return r.next.SetTeamParent(ctx, teamName, parentTeam)
+}
+
+func (r *Repository) GetTeamAncestors(ctx context.Context, teamName string) (_ []string, err error) {
+	ctx, done := r.begin(ctx, "GetTeamAncestors", tracing.Team(teamName))
+	defer func() { done(err) }()
+	return r.next.GetTeamAncestors(ctx, teamName)
+}
+
+func (r *Repository) GetTeamSubtree(ctx context.Context, teamName string) (_ []models.TeamNode, err error) {
+	ctx, done := r.begin(ctx, "GetTeamSubtree", tracing.Team(teamName))
+	defer func() { done(err) }()
+	return r.next.GetTeamSubtree(ctx, teamName)
+}
+
+func (r *Repository) GetTeamsByNames(ctx context.Context, teamNames []string) (_ []models.Team, err error) {
+	ctx, done := r.begin(ctx, "GetTeamsByNames", tracing.Teams(teamNames))
+	defer func() { done(err) }()
+	return r.next.GetTeamsByNames(ctx, teamNames)
+}
+
+func (r *Repository) GetChildTeams(ctx context.Context, teamNames []string) (_ []models.TeamNode, err error) {
+	ctx, done := r.begin(ctx, "GetChildTeams", tracing.Teams(teamNames))
+	defer func() { done(err) }()
+	return r.next.GetChildTeams(ctx, teamNames)
+}
+
+func (r *Repository) UpdateUserActivity(ctx context.Context, userID string, isActive bool) (_ *models.User, err error) {
+	ctx, done := r.begin(ctx, "UpdateUserActivity", tracing.User(userID))
+	defer func() { done(err) }()
+	return r.next.UpdateUserActivity(ctx, userID, isActive)
+}
+
+func (r *Repository) GetUser(ctx context.Context, userID string) (_ *models.User, err error) {
+	ctx, done := r.begin(ctx, "GetUser", tracing.User(userID))
+	defer func() { done(err) }()
+	return r.next.GetUser(ctx, userID)
+}
+
+func (r *Repository) GetUsersByTeam(ctx context.Context, teamName string) (_ []*models.User, err error) {
+	ctx, done := r.begin(ctx, "GetUsersByTeam", tracing.Team(teamName))
+	defer func() { done(err) }()
+	return r.next.GetUsersByTeam(ctx, teamName)
+}
+
+func (r *Repository) GetUsersByIDs(ctx context.Context, userIDs []string) (_ []*models.User, err error) {
+	ctx, done := r.begin(ctx, "GetUsersByIDs", tracing.Users(userIDs))
+	defer func() { done(err) }()
+	return r.next.GetUsersByIDs(ctx, userIDs)
+}
+
+func (r *Repository) ListUsers(ctx context.Context, filter models.UserFilter) (_ []models.User, err error) {
+	ctx, done := r.begin(ctx, "ListUsers")
+	defer func() { done(err) }()
+	return r.next.ListUsers(ctx, filter)
+}
+
+func (r *Repository) UpdateUser(ctx context.Context, userID, username, teamName string) (_ *models.User, err error) {
+	ctx, done := r.begin(ctx, "UpdateUser", tracing.User(userID), tracing.Team(teamName))
+	defer func() { done(err) }()
+	return r.next.UpdateUser(ctx, userID, username, teamName)
+}
+
+func (r *Repository) CreatePR(ctx context.Context, pr *models.PullRequest) (err error) {
+	ctx, done := r.begin(ctx, "CreatePR")
+	defer func() { done(err) }()
+	return r.next.CreatePR(ctx, pr)
+}
+
+func (r *Repository) GetPR(ctx context.Context, prID string) (_ *models.PullRequest, err error) {
+	ctx, done := r.begin(ctx, "GetPR", tracing.PRID(prID))
+	defer func() { done(err) }()
+	return r.next.GetPR(ctx, prID)
+}
+
+func (r *Repository) UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus, mergedAt *time.Time, expectedVersion int64) (_ int64, err error) {
+	ctx, done := r.begin(ctx, "UpdatePRStatus", tracing.PRID(prID))
+	defer func() { done(err) }()
+	return r.next.UpdatePRStatus(ctx, prID, status, mergedAt, expectedVersion)
+}
+
+func (r *Repository) UpdatePRReviewers(ctx context.Context, prID string, reviewers []string, expectedVersion int64) (_ int64, err error) {
+	ctx, done := r.begin(ctx, "UpdatePRReviewers", tracing.PRID(prID))
+	defer func() { done(err) }()
+	return r.next.UpdatePRReviewers(ctx, prID, reviewers, expectedVersion)
+}
+
+func (r *Repository) GetPRsByReviewer(ctx context.Context, userID string, filter models.ReviewFilter) (_ []models.PullRequestShort, err error) {
+	ctx, done := r.begin(ctx, "GetPRsByReviewer", tracing.User(userID))
+	defer func() { done(err) }()
+	return r.next.GetPRsByReviewer(ctx, userID, filter)
+}
+
+func (r *Repository) GetOpenPRsByReviewers(ctx context.Context, userIDs []string) (_ map[string][]models.PullRequest, err error) {
+	ctx, done := r.begin(ctx, "GetOpenPRsByReviewers", tracing.Users(userIDs))
+	defer func() { done(err) }()
+	return r.next.GetOpenPRsByReviewers(ctx, userIDs)
+}
+
+func (r *Repository) ListPRs(ctx context.Context, filter models.PRListFilter) (_ []models.PullRequest, err error) {
+	ctx, done := r.begin(ctx, "ListPRs")
+	defer func() { done(err) }()
+	return r.next.ListPRs(ctx, filter)
+}
+
+func (r *Repository) StreamPRs(ctx context.Context, filter models.PRListFilter, fn func(models.PullRequest) error) (err error) {
+	ctx, done := r.begin(ctx, "StreamPRs")
+	defer func() { done(err) }()
+	return r.next.StreamPRs(ctx, filter, fn)
+}
+
+func (r *Repository) PRExists(ctx context.Context, prID string) (_ bool, err error) {
+	ctx, done := r.begin(ctx, "PRExists", tracing.PRID(prID))
+	defer func() { done(err) }()
+	return r.next.PRExists(ctx, prID)
+}
+
+func (r *Repository) AddPREvents(ctx context.Context, events []models.PREvent) (err error) {
+	ctx, done := r.begin(ctx, "AddPREvents")
+	defer func() { done(err) }()
+	return r.next.AddPREvents(ctx, events)
+}
+
+func (r *Repository) GetPREvents(ctx context.Context, prID string) (_ []models.PREvent, err error) {
+	ctx, done := r.begin(ctx, "GetPREvents", tracing.PRID(prID))
+	defer func() { done(err) }()
+	return r.next.GetPREvents(ctx, prID)
+}
+
+func (r *Repository) StreamPREvents(ctx context.Context, filter models.PREventFilter, fn func(models.PREvent) error) (err error) {
+	ctx, done := r.begin(ctx, "StreamPREvents")
+	defer func() { done(err) }()
+	return r.next.StreamPREvents(ctx, filter, fn)
+}
+
+func (r *Repository) GetReviewStats(ctx context.Context, teamNames []string, window models.TimeWindow, after *models.StatCursor, limit int) (_ []models.ReviewStat, err error) {
+	ctx, done := r.begin(ctx, "GetReviewStats", tracing.Teams(teamNames))
+	defer func() { done(err) }()
+	return r.next.GetReviewStats(ctx, teamNames, window, after, limit)
+}
+
+func (r *Repository) StreamReviewStats(ctx context.Context, teamNames []string, window models.TimeWindow, after *models.StatCursor, limit int, fn func(models.ReviewStat) error) (err error) {
+	ctx, done := r.begin(ctx, "StreamReviewStats", tracing.Teams(teamNames))
+	defer func() { done(err) }()
+	return r.next.StreamReviewStats(ctx, teamNames, window, after, limit, fn)
+}
+
+func (r *Repository) GetTeamReviewCounts(ctx context.Context, teamNames []string, window models.TimeWindow) (_ []models.TeamReviewStat, err error) {
+	ctx, done := r.begin(ctx, "GetTeamReviewCounts", tracing.Teams(teamNames))
+	defer func() { done(err) }()
+	return r.next.GetTeamReviewCounts(ctx, teamNames, window)
+}
+
+func (r *Repository) GetTurnaround(ctx context.Context, filter models.TurnaroundFilter) (_ []models.TurnaroundRow, err error) {
+	ctx, done := r.begin(ctx, "GetTurnaround")
+	defer func() { done(err) }()
+	return r.next.GetTurnaround(ctx, filter)
+}
+
+func (r *Repository) GetReviewPairs(ctx context.Context, filter models.PairFilter) (_ []models.ReviewPair, err error) {
+	ctx, done := r.begin(ctx, "GetReviewPairs")
+	defer func() { done(err) }()
+	return r.next.GetReviewPairs(ctx, filter)
+}
+
+func (r *Repository) GetTeamWorkloads(ctx context.Context) (_ []models.TeamWorkload, err error) {
+	ctx, done := r.begin(ctx, "GetTeamWorkloads")
+	defer func() { done(err) }()
+	return r.next.GetTeamWorkloads(ctx)
+}
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *models.APIKey, keyHash string) (err error) {
+	ctx, done := r.begin(ctx, "CreateAPIKey")
+	defer func() { done(err) }()
+	return r.next.CreateAPIKey(ctx, key, keyHash)
+}
+
+func (r *Repository) GetAPIKeyByHash(ctx context.Context, keyHash string) (_ *models.APIKey, err error) {
+	ctx, done := r.begin(ctx, "GetAPIKeyByHash")
+	defer func() { done(err) }()
+	return r.next.GetAPIKeyByHash(ctx, keyHash)
+}
+
+func (r *Repository) GetAPIKey(ctx context.Context, keyID string) (_ *models.APIKey, err error) {
+	ctx, done := r.begin(ctx, "GetAPIKey", tracing.APIKey(keyID))
+	defer func() { done(err) }()
+	return r.next.GetAPIKey(ctx, keyID)
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context) (_ []models.APIKey, err error) {
+	ctx, done := r.begin(ctx, "ListAPIKeys")
+	defer func() { done(err) }()
+	return r.next.ListAPIKeys(ctx)
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, keyID string, revokedAt time.Time) (err error) {
+	ctx, done := r.begin(ctx, "RevokeAPIKey", tracing.APIKey(keyID))
+	defer func() { done(err) }()
+	return r.next.RevokeAPIKey(ctx, keyID, revokedAt)
+}
+
+func (r *Repository) TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) (err error) {
+	ctx, done := r.begin(ctx, "TouchAPIKey", tracing.APIKey(keyID))
+	defer func() { done(err) }()
+	return r.next.TouchAPIKey(ctx, keyID, usedAt)
+}
+
+func (r *Repository) InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) (err error) {
+	ctx, done := r.begin(ctx, "InsertAuditEntry")
+	defer func() { done(err) }()
+	return r.next.InsertAuditEntry(ctx, entry)
+}
+
+func (r *Repository) ListAuditEntries(ctx context.Context, filter models.AuditFilter) (_ []models.AuditEntry, err error) {
+	ctx, done := r.begin(ctx, "ListAuditEntries")
+	defer func() { done(err) }()
+	return r.next.ListAuditEntries(ctx, filter)
+}
+
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (_ bool, err error) {
+	ctx, done := r.begin(ctx, "ReserveIdempotencyKey")
+	defer func() { done(err) }()
+	return r.next.ReserveIdempotencyKey(ctx, record, staleBefore)
+}
+
+func (r *Repository) GetIdempotencyKey(ctx context.Context, scope, key string) (_ *models.IdempotencyRecord, err error) {
+	ctx, done := r.begin(ctx, "GetIdempotencyKey")
+	defer func() { done(err) }()
+	return r.next.GetIdempotencyKey(ctx, scope, key)
+}
+
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (_ bool, err error) {
+	ctx, done := r.begin(ctx, "CompleteIdempotencyKey")
+	defer func() { done(err) }()
+	return r.next.CompleteIdempotencyKey(ctx, record)
+}
+
+func (r *Repository) DeleteIdempotencyKey(ctx context.Context, reservation *models.IdempotencyRecord) (err error) {
+	ctx, done := r.begin(ctx, "DeleteIdempotencyKey")
+	defer func() { done(err) }()
+	return r.next.DeleteIdempotencyKey(ctx, reservation)
+}
+
+func (r *Repository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, done := r.begin(ctx, "PurgeIdempotencyKeys")
+	defer func() { done(err) }()
+	return r.next.PurgeIdempotencyKeys(ctx, before)
+}
@@ -5,18 +5,62 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/repository"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
+// dbtx - общие методы *sqlx.DB и *sqlx.Tx, чтобы одни и те же запросы работали и в транзакции
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+}
+
 type PostgresRepository struct {
-	db *sqlx.DB
+	conn *sqlx.DB
+	db   dbtx // conn или открытая транзакция
+	inTx bool
 }
 
 func NewPostgresRepository(db *sqlx.DB) *PostgresRepository {
-	return &PostgresRepository{db: db}
+	return &PostgresRepository{conn: db, db: db}
+}
+
+// WithTx выполняет fn в одной транзакции: все вызовы переданного репозитория
+// фиксируются вместе или откатываются, если fn вернула ошибку
+func (r *PostgresRepository) WithTx(ctx context.Context, fn func(tx repository.Repository) error) error {
+	return r.transact(ctx, func(tx *PostgresRepository) error {
+		return fn(tx)
+	})
+}
+
+// transact открывает транзакцию или продолжает уже открытую через WithTx
+func (r *PostgresRepository) transact(ctx context.Context, fn func(tx *PostgresRepository) error) error {
+	if r.inTx {
+		return fn(r)
+	}
+
+	tx, err := r.conn.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&PostgresRepository{conn: r.conn, db: tx, inTx: true}); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
 }
 
 func ConnectToDatabase(connectionString string) (*sqlx.DB, error) {
@@ -33,45 +77,55 @@ func ConnectToDatabase(connectionString string) (*sqlx.DB, error) {
 }
 
 func (r *PostgresRepository) CreateTeam(ctx context.Context, team *models.Team) error {
-	tx, err := r.db.BeginTxx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	currentTime := time.Now()
-
-	_, err = tx.ExecContext(ctx, `
-        INSERT INTO teams (team_name, created_at) 
-        VALUES ($1, $2) 
-        ON CONFLICT (team_name) DO NOTHING
-    `, team.TeamName, currentTime)
-	if err != nil {
-		return fmt.Errorf("failed to insert team: %w", err)
-	}
+	return r.transact(ctx, func(tx *PostgresRepository) error {
+		currentTime := time.Now()
 
-	for _, member := range team.Members {
-		_, err = tx.ExecContext(ctx, `
-            INSERT INTO users (user_id, username, team_name, is_active, created_at) 
-            VALUES ($1, $2, $3, $4, $5)
-            ON CONFLICT (user_id) DO UPDATE SET
-                username = EXCLUDED.username,
-                team_name = EXCLUDED.team_name,
-                is_active = EXCLUDED.is_active
-        `, member.UserID, member.Username, team.TeamName, member.IsActive, currentTime)
+		_, err := tx.db.ExecContext(ctx, `
+            INSERT INTO teams (team_name, parent_team, created_at) 
+            VALUES ($1, NULLIF($2, ''), $3) 
+            ON CONFLICT (team_name) DO NOTHING
+        `, team.TeamName, team.ParentTeam, currentTime)
 		if err != nil {
-			return fmt.Errorf("failed to insert user %s: %w", member.UserID, err)
+			return fmt.Errorf("failed to insert team: %w", err)
+		}
+
+		for _, member := range team.Members {
+			_, err = tx.db.ExecContext(ctx, `
+                INSERT INTO users (user_id, username, team_name, is_active, created_at) 
+                VALUES ($1, $2, $3, $4, $5)
+                ON CONFLICT (user_id) DO UPDATE SET
+                    username = EXCLUDED.username,
+                    team_name = EXCLUDED.team_name,
+                    is_active = EXCLUDED.is_active
+            `, member.UserID, member.Username, team.TeamName, member.IsActive, currentTime)
+			if err != nil {
+				return fmt.Errorf("failed to insert user %s: %w", member.UserID, err)
+			}
 		}
-	}
 
-	return tx.Commit()
+		return nil
+	})
 }
 
 func (r *PostgresRepository) GetTeam(ctx context.Context, teamName string) (*models.Team, error) {
 	var team models.Team
-	team.TeamName = teamName
 
-	err := r.db.SelectContext(ctx, &team.Members, `
+	err := r.db.QueryRowContext(ctx, `
+        SELECT team_name, COALESCE(parent_team, ''), version
+        FROM teams 
+        WHERE team_name = $1
+    `, teamName).Scan(&team.TeamName, &team.ParentTeam, &team.Version)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get team: %w", err)
+	}
+
+	// у верхних узлов иерархии (org, department) участников может не быть
+	team.Members = []models.TeamMember{}
+	err = r.db.SelectContext(ctx, &team.Members, `
         SELECT user_id, username, is_active
         FROM users 
         WHERE team_name = $1
@@ -79,17 +133,75 @@ func (r *PostgresRepository) GetTeam(ctx context.Context, teamName string) (*mod
     `, teamName)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
 		return nil, fmt.Errorf("failed to get team members: %w", err)
 	}
 
-	if len(team.Members) == 0 {
-		return nil, nil
+	return &team, nil
+}
+
+func (r *PostgresRepository) SetTeamParent(ctx context.Context, teamName, parentTeam string) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE teams 
+        SET parent_team = NULLIF($1, '') 
+        WHERE team_name = $2
+    `, parentTeam, teamName)
+
+	if err != nil {
+		return fmt.Errorf("failed to set parent team: %w", err)
 	}
 
-	return &team, nil
+	return nil
+}
+
+// GetTeamAncestors возвращает команду и всех её предков вверх по иерархии
+func (r *PostgresRepository) GetTeamAncestors(ctx context.Context, teamName string) ([]string, error) {
+	var ancestors []string
+
+	// UNION (а не UNION ALL) гарантирует завершение даже на испорченных данных с циклом
+	err := r.db.SelectContext(ctx, &ancestors, `
+        WITH RECURSIVE ancestors AS (
+            SELECT team_name, parent_team
+            FROM teams 
+            WHERE team_name = $1
+            UNION
+            SELECT t.team_name, t.parent_team
+            FROM teams t
+            JOIN ancestors a ON t.team_name = a.parent_team
+        )
+        SELECT team_name FROM ancestors
+    `, teamName)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team ancestors: %w", err)
+	}
+
+	return ancestors, nil
+}
+
+// GetTeamSubtree возвращает плоский список узлов поддерева, включая корень
+func (r *PostgresRepository) GetTeamSubtree(ctx context.Context, teamName string) ([]models.TeamNode, error) {
+	var nodes []models.TeamNode
+
+	err := r.db.SelectContext(ctx, &nodes, `
+        WITH RECURSIVE subtree AS (
+            SELECT team_name, parent_team
+            FROM teams 
+            WHERE team_name = $1
+            UNION
+            SELECT t.team_name, t.parent_team
+            FROM teams t
+            JOIN subtree s ON t.parent_team = s.team_name
+        )
+        SELECT team_name, COALESCE(parent_team, '') AS parent_team
+        FROM subtree
+        ORDER BY team_name
+    `, teamName)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team subtree: %w", err)
+	}
+
+	return nodes, nil
 }
 
 func (r *PostgresRepository) GetUser(ctx context.Context, userID string) (*models.User, error) {
@@ -143,10 +255,11 @@ func (r *PostgresRepository) CreatePR(ctx context.Context, pr *models.PullReques
 		return fmt.Errorf("failed to marshal reviewers: %w", err)
 	}
 
-	_, err = r.db.ExecContext(ctx, `
+	err = r.db.GetContext(ctx, &pr.Version, `
         INSERT INTO pull_requests 
         (pull_request_id, pull_request_name, author_id, status, assigned_reviewers, created_at) 
         VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING version
     `, pr.PullRequestID, pr.PullRequestName, pr.AuthorID, pr.Status, reviewersJSON, pr.CreatedAt)
 
 	if err != nil {
@@ -161,12 +274,12 @@ func (r *PostgresRepository) GetPR(ctx context.Context, prID string) (*models.Pu
 	var reviewersJSON []byte
 
 	err := r.db.QueryRowContext(ctx, `
-        SELECT pull_request_id, pull_request_name, author_id, status, assigned_reviewers, created_at, merged_at
+        SELECT pull_request_id, pull_request_name, author_id, status, assigned_reviewers, created_at, merged_at, version
         FROM pull_requests 
         WHERE pull_request_id = $1
     `, prID).Scan(
 		&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status,
-		&reviewersJSON, &pr.CreatedAt, &pr.MergedAt,
+		&reviewersJSON, &pr.CreatedAt, &pr.MergedAt, &pr.Version,
 	)
 
 	if err != nil {
@@ -196,84 +309,640 @@ func (r *PostgresRepository) PRExists(ctx context.Context, prID string) (bool, e
 	return exists, nil
 }
 
-func (r *PostgresRepository) UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus, mergedAt *time.Time) error {
-	_, err := r.db.ExecContext(ctx, `
+// UpdatePRStatus и UpdatePRReviewers возвращают новую версию PR. При expectedVersion > 0
+// строка обновляется, только если её версия не изменилась; 0 в ответе - версия не совпала
+func (r *PostgresRepository) UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus, mergedAt *time.Time, expectedVersion int64) (int64, error) {
+	var version int64
+	err := r.db.GetContext(ctx, &version, `
         UPDATE pull_requests 
         SET status = $1, merged_at = $2 
-        WHERE pull_request_id = $3
-    `, status, mergedAt, prID)
+        WHERE pull_request_id = $3 AND ($4 = 0 OR version = $4)
+        RETURNING version
+    `, status, mergedAt, prID, expectedVersion)
 
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to update PR status: %w", err)
+		return 0, fmt.Errorf("failed to update PR status: %w", err)
 	}
 
-	return nil
+	return version, nil
 }
 
-func (r *PostgresRepository) UpdatePRReviewers(ctx context.Context, prID string, reviewers []string) error {
+func (r *PostgresRepository) UpdatePRReviewers(ctx context.Context, prID string, reviewers []string, expectedVersion int64) (int64, error) {
 	reviewersJSON, err := json.Marshal(reviewers)
 	if err != nil {
-		return fmt.Errorf("failed to marshal reviewers: %w", err)
+		return 0, fmt.Errorf("failed to marshal reviewers: %w", err)
 	}
 
-	_, err = r.db.ExecContext(ctx, `
+	var version int64
+	err = r.db.GetContext(ctx, &version, `
         UPDATE pull_requests 
         SET assigned_reviewers = $1 
-        WHERE pull_request_id = $2
-    `, reviewersJSON, prID)
+        WHERE pull_request_id = $2 AND ($3 = 0 OR version = $3)
+        RETURNING version
+    `, reviewersJSON, prID, expectedVersion)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update PR reviewers: %w", err)
+	}
+
+	return version, nil
+}
+
+func (r *PostgresRepository) AddPREvents(ctx context.Context, events []models.PREvent) error {
+	return r.transact(ctx, func(tx *PostgresRepository) error {
+		for i := range events {
+			event := &events[i]
+			err := tx.db.QueryRowContext(ctx, `
+                INSERT INTO pr_events (pull_request_id, event_type, user_id, cause, verdict, actor, created_at)
+                VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, $7)
+                RETURNING id
+            `, event.PullRequestID, event.EventType, event.UserID, event.Cause, event.Verdict, event.Actor, event.CreatedAt,
+			).Scan(&event.ID)
+			if err != nil {
+				return fmt.Errorf("failed to insert PR event: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetPREvents возвращает историю PR в хронологическом порядке
+func (r *PostgresRepository) GetPREvents(ctx context.Context, prID string) ([]models.PREvent, error) {
+	events := []models.PREvent{}
+
+	err := r.db.SelectContext(ctx, &events, `
+        SELECT id, pull_request_id, event_type, COALESCE(user_id, '') AS user_id,
+               COALESCE(cause, '') AS cause, COALESCE(verdict, '') AS verdict, actor, created_at
+        FROM pr_events
+        WHERE pull_request_id = $1
+        ORDER BY created_at, id
+    `, prID)
 
 	if err != nil {
-		return fmt.Errorf("failed to update PR reviewers: %w", err)
+		return nil, fmt.Errorf("failed to get PR events: %w", err)
+	}
+
+	return events, nil
+}
+
+// StreamPREvents передаёт события истории в fn в хронологическом порядке по мере чтения
+func (r *PostgresRepository) StreamPREvents(ctx context.Context, filter models.PREventFilter, fn func(models.PREvent) error) error {
+	rows, err := r.db.QueryxContext(ctx, `
+        SELECT e.id, e.pull_request_id, e.event_type, COALESCE(e.user_id, '') AS user_id,
+               COALESCE(e.cause, '') AS cause, COALESCE(e.verdict, '') AS verdict, e.actor, e.created_at
+        FROM pr_events e
+        WHERE ($1::timestamp IS NULL OR e.created_at >= $1)
+        AND ($2::timestamp IS NULL OR e.created_at < $2)
+        AND ($3::text[] IS NULL OR e.event_type = ANY($3))
+        AND ($4 = '' OR e.pull_request_id IN (
+            SELECT pr.pull_request_id
+            FROM pull_requests pr
+            JOIN users u ON u.user_id = pr.author_id
+            WHERE u.team_name = $4
+        ))
+        ORDER BY e.created_at, e.id
+    `, filter.From, filter.To, pq.Array(filter.EventTypes), filter.TeamName)
+
+	if err != nil {
+		return fmt.Errorf("failed to get PR events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.PREvent
+		if err := rows.StructScan(&event); err != nil {
+			return fmt.Errorf("failed to scan PR event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get PR events: %w", err)
 	}
 
 	return nil
 }
 
-func (r *PostgresRepository) GetPRsByReviewer(ctx context.Context, userID string) ([]models.PullRequestShort, error) {
-	var prs []models.PullRequestShort
+// GetPRsByReviewer - PR, на которые назначен пользователь, с keyset-пагинацией по
+// (created_at, pull_request_id). PR без created_at (старые записи) считаются самыми ранними
+func (r *PostgresRepository) GetPRsByReviewer(ctx context.Context, userID string, filter models.ReviewFilter) ([]models.PullRequestShort, error) {
+	prs := []models.PullRequestShort{}
+
+	// направление подставляется из констант, значения фильтра - только параметрами
+	order, cmp := "DESC", "<"
+	if filter.Ascending {
+		order, cmp = "ASC", ">"
+	}
+
+	var afterTime *time.Time
+	var afterID string
+	if filter.After != nil {
+		afterTime = &filter.After.CreatedAt
+		afterID = filter.After.PullRequestID
+	}
 
-	err := r.db.SelectContext(ctx, &prs, `
-        SELECT pull_request_id, pull_request_name, author_id, status
+	query := fmt.Sprintf(`
+        SELECT pull_request_id, pull_request_name, author_id, status, created_at
         FROM pull_requests 
         WHERE assigned_reviewers @> $1
-        ORDER BY created_at DESC
-    `, fmt.Sprintf(`["%s"]`, userID))
+        AND ($2 = '' OR status = $2)
+        AND ($3::timestamp IS NULL OR created_at >= $3)
+        AND ($4::timestamp IS NULL OR created_at < $4)
+        AND ($5::timestamp IS NULL OR (COALESCE(created_at, 'epoch'), pull_request_id) %s ($5, $6))
+        ORDER BY COALESCE(created_at, 'epoch') %s, pull_request_id %s
+        LIMIT NULLIF($7, 0)
+    `, cmp, order, order)
+
+	err := r.db.SelectContext(ctx, &prs, query,
+		fmt.Sprintf(`["%s"]`, userID), string(filter.Status),
+		filter.CreatedAfter, filter.CreatedBefore, afterTime, afterID, filter.Limit)
 
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return []models.PullRequestShort{}, nil
-		}
 		return nil, fmt.Errorf("failed to get PRs by reviewer: %w", err)
 	}
 
 	return prs, nil
 }
 
-// GetReviewStats получает статистику по количеству PR у каждого пользователя
-func (r *PostgresRepository) GetReviewStats(ctx context.Context) ([]models.ReviewStat, error) {
+// ListPRs - листинг с фильтрами и той же keyset-пагинацией, что у GetPRsByReviewer.
+// Поиск по названию - ILIKE по подстроке, его ускоряет триграммный индекс idx_pr_name_trgm
+func (r *PostgresRepository) ListPRs(ctx context.Context, filter models.PRListFilter) ([]models.PullRequest, error) {
+	prs := []models.PullRequest{}
+	err := r.StreamPRs(ctx, filter, func(pr models.PullRequest) error {
+		prs = append(prs, pr)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return prs, nil
+}
+
+// StreamPRs выполняет запрос ListPRs и передаёт PR в fn по мере чтения из базы,
+// не собирая результат в память. Ошибка fn прерывает чтение
+func (r *PostgresRepository) StreamPRs(ctx context.Context, filter models.PRListFilter, fn func(models.PullRequest) error) error {
+	order, cmp := "DESC", "<"
+	if filter.Ascending {
+		order, cmp = "ASC", ">"
+	}
+
+	var afterTime *time.Time
+	var afterID string
+	if filter.After != nil {
+		afterTime = &filter.After.CreatedAt
+		afterID = filter.After.PullRequestID
+	}
+
+	query := fmt.Sprintf(`
+        SELECT pull_request_id, pull_request_name, author_id, status, assigned_reviewers, created_at, merged_at
+        FROM pull_requests 
+        WHERE ($1 = '' OR author_id = $1)
+        AND ($2 = '' OR author_id IN (SELECT user_id FROM users WHERE team_name = $2))
+        AND ($3 = '' OR status = $3)
+        AND ($4 = '' OR assigned_reviewers @> jsonb_build_array($4::text))
+        AND ($5::timestamp IS NULL OR created_at >= $5)
+        AND ($6::timestamp IS NULL OR created_at < $6)
+        AND ($7::timestamp IS NULL OR merged_at >= $7)
+        AND ($8::timestamp IS NULL OR merged_at < $8)
+        AND ($9 = '' OR pull_request_name ILIKE '%%' || $9 || '%%')
+        AND ($10::timestamp IS NULL OR (COALESCE(created_at, 'epoch'), pull_request_id) %s ($10, $11))
+        ORDER BY COALESCE(created_at, 'epoch') %s, pull_request_id %s
+        LIMIT NULLIF($12, 0)
+    `, cmp, order, order)
+
+	rows, err := r.db.QueryContext(ctx, query,
+		filter.AuthorID, filter.TeamName, string(filter.Status), filter.ReviewerID,
+		filter.CreatedAfter, filter.CreatedBefore, filter.MergedAfter, filter.MergedBefore,
+		escapeLike(filter.Query), afterTime, afterID, filter.Limit)
+
+	if err != nil {
+		return fmt.Errorf("failed to list PRs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pr models.PullRequest
+		var reviewersJSON []byte
+		if err := rows.Scan(
+			&pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status,
+			&reviewersJSON, &pr.CreatedAt, &pr.MergedAt,
+		); err != nil {
+			return fmt.Errorf("failed to scan PR: %w", err)
+		}
+		if err := json.Unmarshal(reviewersJSON, &pr.AssignedReviewers); err != nil {
+			return fmt.Errorf("failed to unmarshal reviewers: %w", err)
+		}
+		if err := fn(pr); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to list PRs: %w", err)
+	}
+
+	return nil
+}
+
+// escapeLike экранирует спецсимволы LIKE, чтобы запрос искался как обычная подстрока
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// GetOpenPRsByReviewers - открытые PR, сгруппированные по каждому из ревьюверов
+func (r *PostgresRepository) GetOpenPRsByReviewers(ctx context.Context, userIDs []string) (map[string][]models.PullRequest, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        SELECT rv.reviewer, pr.pull_request_id, pr.pull_request_name, pr.author_id, pr.status,
+               pr.assigned_reviewers, pr.created_at, pr.merged_at
+        FROM pull_requests pr
+        CROSS JOIN LATERAL jsonb_array_elements_text(pr.assigned_reviewers) AS rv(reviewer)
+        WHERE pr.status = 'OPEN'
+        AND rv.reviewer = ANY($1)
+        ORDER BY pr.created_at DESC
+    `, pq.Array(userIDs))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get PRs by reviewers: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string][]models.PullRequest)
+	for rows.Next() {
+		var (
+			reviewer      string
+			pr            models.PullRequest
+			reviewersJSON []byte
+		)
+		if err := rows.Scan(
+			&reviewer, &pr.PullRequestID, &pr.PullRequestName, &pr.AuthorID, &pr.Status,
+			&reviewersJSON, &pr.CreatedAt, &pr.MergedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan PR: %w", err)
+		}
+		if err := json.Unmarshal(reviewersJSON, &pr.AssignedReviewers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal reviewers: %w", err)
+		}
+		result[reviewer] = append(result[reviewer], pr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to get PRs by reviewers: %w", err)
+	}
+
+	return result, nil
+}
+
+// prEndsCTE - моменты мержа и завершения PR (мерж или закрытие). После завершения
+// назначения PR уже не считаются открытыми ревью
+const prEndsCTE = `ends AS (
+            SELECT pull_request_id,
+                MIN(created_at) FILTER (WHERE event_type = 'merged') AS merged_at,
+                MIN(created_at) AS ended_at
+            FROM pr_events
+            WHERE event_type IN ('merged', 'closed')
+            GROUP BY pull_request_id
+        )`
+
+// reviewWindowCTE - общая часть статистики по истории назначений. $1 - команды (NULL - все),
+// $2 и $3 - границы периода. assignments - последнее событие назначения каждой пары
+// (PR, ревьювер) до конца периода, ends - мерж или закрытие PR
+const reviewWindowCTE = `
+        WITH bounds AS (
+            SELECT COALESCE($2::timestamp, '-infinity') AS from_ts,
+                   COALESCE($3::timestamp, 'infinity') AS to_ts
+        ),
+        members AS (
+            SELECT user_id, username, team_name
+            FROM users
+            WHERE $1::text[] IS NULL OR team_name = ANY($1)
+        ),
+        assignments AS (
+            SELECT DISTINCT ON (e.pull_request_id, e.user_id) e.pull_request_id, e.user_id, e.event_type
+            FROM pr_events e
+            CROSS JOIN bounds b
+            WHERE e.event_type IN ('reviewer_assigned', 'reviewer_unassigned')
+            AND e.user_id IN (SELECT user_id FROM members)
+            AND e.created_at < b.to_ts
+            ORDER BY e.pull_request_id, e.user_id, e.created_at DESC, e.id DESC
+        ),
+        ` + prEndsCTE + `,
+        reviews AS (
+            SELECT a.user_id,
+                COUNT(*) FILTER (WHERE m.ended_at IS NULL OR m.ended_at >= b.to_ts) AS review_count,
+                COUNT(*) FILTER (WHERE m.merged_at >= b.from_ts AND m.merged_at < b.to_ts) AS reviews_completed
+            FROM assignments a
+            CROSS JOIN bounds b
+            LEFT JOIN ends m ON m.pull_request_id = a.pull_request_id
+            WHERE a.event_type = 'reviewer_assigned'
+            GROUP BY a.user_id
+        )`
+
+// GetReviewStats - статистика ревьюверов за период по истории назначений (pr_events):
+// ревью, открытые на конец периода, назначенные в периоде, завершённые (PR смержен
+// в периоде, пока ревьювер был назначен), созданные PR и снятия с ревью.
+// Keyset-пагинация по (review_count DESC, user_id).
+// Пустой teamNames - без фильтра по командам, limit 0 - без ограничения
+func (r *PostgresRepository) GetReviewStats(ctx context.Context, teamNames []string, window models.TimeWindow, after *models.StatCursor, limit int) ([]models.ReviewStat, error) {
 	var stats []models.ReviewStat
+	err := r.StreamReviewStats(ctx, teamNames, window, after, limit, func(stat models.ReviewStat) error {
+		stats = append(stats, stat)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
 
-	query := `
-        SELECT 
-            u.user_id, 
-            u.username,
-            (SELECT COUNT(*) 
-             FROM pull_requests pr 
-             WHERE pr.status = 'OPEN' 
-             AND pr.assigned_reviewers @> jsonb_build_array(u.user_id)
-            ) as review_count
-        FROM users u
-        ORDER BY review_count DESC
+// StreamReviewStats выполняет запрос GetReviewStats и передаёт строки в fn по мере чтения
+func (r *PostgresRepository) StreamReviewStats(ctx context.Context, teamNames []string, window models.TimeWindow, after *models.StatCursor, limit int, fn func(models.ReviewStat) error) error {
+	var afterCount *int
+	var afterID string
+	if after != nil {
+		afterCount = &after.ReviewCount
+		afterID = after.UserID
+	}
+
+	query := reviewWindowCTE + `,
+        authored AS (
+            SELECT pr.author_id AS user_id, COUNT(*) AS prs_authored
+            FROM pr_events e
+            JOIN pull_requests pr ON pr.pull_request_id = e.pull_request_id
+            CROSS JOIN bounds b
+            WHERE e.event_type = 'created'
+            AND e.created_at >= b.from_ts AND e.created_at < b.to_ts
+            GROUP BY pr.author_id
+        ),
+        assigned AS (
+            SELECT e.user_id, COUNT(*) AS reviews_assigned
+            FROM pr_events e
+            CROSS JOIN bounds b
+            WHERE e.event_type = 'reviewer_assigned'
+            AND e.created_at >= b.from_ts AND e.created_at < b.to_ts
+            GROUP BY e.user_id
+        ),
+        reassigned AS (
+            SELECT e.user_id, COUNT(*) AS reassigned_away
+            FROM pr_events e
+            CROSS JOIN bounds b
+            WHERE e.event_type = 'reviewer_unassigned'
+            AND e.created_at >= b.from_ts AND e.created_at < b.to_ts
+            GROUP BY e.user_id
+        ),
+        stats AS (
+            SELECT 
+                u.user_id,
+                u.username,
+                COALESCE(rv.review_count, 0) AS review_count,
+                COALESCE(ag.reviews_assigned, 0) AS reviews_assigned,
+                COALESCE(rv.reviews_completed, 0) AS reviews_completed,
+                COALESCE(au.prs_authored, 0) AS prs_authored,
+                COALESCE(ra.reassigned_away, 0) AS reassigned_away
+            FROM members u
+            LEFT JOIN reviews rv ON rv.user_id = u.user_id
+            LEFT JOIN assigned ag ON ag.user_id = u.user_id
+            LEFT JOIN authored au ON au.user_id = u.user_id
+            LEFT JOIN reassigned ra ON ra.user_id = u.user_id
+        )
+        SELECT user_id, username, review_count, reviews_assigned, reviews_completed, prs_authored, reassigned_away
+        FROM stats
+        WHERE $4::int IS NULL OR review_count < $4 OR (review_count = $4 AND user_id > $5)
+        ORDER BY review_count DESC, user_id
+        LIMIT NULLIF($6, 0)
     `
 
-	err := r.db.SelectContext(ctx, &stats, query)
+	rows, err := r.db.QueryxContext(ctx, query,
+		pq.Array(teamNames), window.From, window.To, afterCount, afterID, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get review stats: %w", err)
+		return fmt.Errorf("failed to get review stats: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stat models.ReviewStat
+		if err := rows.StructScan(&stat); err != nil {
+			return fmt.Errorf("failed to scan review stat: %w", err)
+		}
+		if err := fn(stat); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to get review stats: %w", err)
+	}
+
+	return nil
+}
+
+// GetTeamReviewCounts - ревью участников каждой команды, открытые на конец периода
+func (r *PostgresRepository) GetTeamReviewCounts(ctx context.Context, teamNames []string, window models.TimeWindow) ([]models.TeamReviewStat, error) {
+	var stats []models.TeamReviewStat
+
+	err := r.db.SelectContext(ctx, &stats, reviewWindowCTE+`
+        SELECT 
+            t.team_name,
+            COALESCE(t.parent_team, '') AS parent_team,
+            (SELECT COALESCE(SUM(rv.review_count), 0)::int
+             FROM reviews rv
+             JOIN members u ON u.user_id = rv.user_id
+             WHERE u.team_name = t.team_name
+            ) AS review_count
+        FROM teams t
+        WHERE t.team_name = ANY($1)
+        ORDER BY t.team_name
+    `, pq.Array(teamNames), window.From, window.To)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team review counts: %w", err)
 	}
 
 	return stats, nil
 }
 
+// GetReviewPairs - назначения ревьюверов по парам автор -> ревьювер из истории (pr_events),
+// самые частые пары первыми
+func (r *PostgresRepository) GetReviewPairs(ctx context.Context, filter models.PairFilter) ([]models.ReviewPair, error) {
+	pairs := []models.ReviewPair{}
+
+	err := r.db.SelectContext(ctx, &pairs, `
+        SELECT pr.author_id, e.user_id AS reviewer_id, COUNT(*) AS count
+        FROM pr_events e
+        JOIN pull_requests pr ON pr.pull_request_id = e.pull_request_id
+        WHERE e.event_type = 'reviewer_assigned'
+        AND ($1::timestamp IS NULL OR e.created_at >= $1)
+        AND ($2::timestamp IS NULL OR e.created_at < $2)
+        AND ($3 = '' OR pr.author_id IN (SELECT user_id FROM users WHERE team_name = $3))
+        AND ($4 = '' OR pr.author_id = $4)
+        GROUP BY pr.author_id, e.user_id
+        ORDER BY count DESC, pr.author_id, e.user_id
+    `, filter.From, filter.To, filter.TeamName, filter.AuthorID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review pairs: %w", err)
+	}
+
+	return pairs, nil
+}
+
+// turnaroundSamples - замеры длительностей для каждой группировки: group_key, team_name
+// (команда, по которой фильтрует ?team), metric, end_at (момент завершения замера) и duration.
+// Для команд замеры идут по PR автора от создания PR, для ревьювера - от его назначения
+var turnaroundSamples = map[string]string{
+	models.TurnaroundByTeam: `
+        reviews AS (
+            SELECT pull_request_id, MIN(created_at) AS reviewed_at,
+                MIN(created_at) FILTER (WHERE verdict = 'approved') AS approved_at
+            FROM pr_events
+            WHERE event_type = 'review_submitted'
+            GROUP BY pull_request_id
+        ),
+        authored AS (
+            SELECT c.pull_request_id, c.created_at, u.team_name
+            FROM created c
+            JOIN pull_requests pr ON pr.pull_request_id = c.pull_request_id
+            JOIN users u ON u.user_id = pr.author_id
+        ),
+        samples AS (
+            SELECT a.team_name AS group_key, a.team_name, 'time_to_first_review' AS metric,
+                r.reviewed_at AS end_at, r.reviewed_at - a.created_at AS duration
+            FROM authored a
+            JOIN reviews r ON r.pull_request_id = a.pull_request_id
+            UNION ALL
+            SELECT a.team_name, a.team_name, 'time_to_approval', r.approved_at, r.approved_at - a.created_at
+            FROM authored a
+            JOIN reviews r ON r.pull_request_id = a.pull_request_id
+            WHERE r.approved_at IS NOT NULL
+            UNION ALL
+            SELECT a.team_name, a.team_name, 'time_to_merge', m.merged_at, m.merged_at - a.created_at
+            FROM authored a
+            JOIN ends m ON m.pull_request_id = a.pull_request_id
+            WHERE m.merged_at IS NOT NULL
+        )`,
+	models.TurnaroundByReviewer: `
+        reviews AS (
+            SELECT pull_request_id, user_id, MIN(created_at) AS reviewed_at,
+                MIN(created_at) FILTER (WHERE verdict = 'approved') AS approved_at
+            FROM pr_events
+            WHERE event_type = 'review_submitted'
+            GROUP BY pull_request_id, user_id
+        ),
+        -- отсчёт от назначения, после которого ревьювер отправил первое решение
+        reviewed_assignments AS (
+            SELECT r.*, (
+                SELECT MAX(e.created_at)
+                FROM pr_events e
+                WHERE e.pull_request_id = r.pull_request_id AND e.user_id = r.user_id
+                AND e.event_type = 'reviewer_assigned' AND e.created_at <= r.reviewed_at
+            ) AS assigned_at
+            FROM reviews r
+        ),
+        -- последнее событие назначения пары (PR, ревьювер): после мержа или закрытия назначения не меняются
+        last_assignments AS (
+            SELECT DISTINCT ON (pull_request_id, user_id) pull_request_id, user_id, event_type, created_at AS assigned_at
+            FROM pr_events
+            WHERE event_type IN ('reviewer_assigned', 'reviewer_unassigned')
+            ORDER BY pull_request_id, user_id, created_at DESC, id DESC
+        ),
+        samples AS (
+            SELECT u.user_id AS group_key, u.team_name, 'time_to_first_review' AS metric,
+                r.reviewed_at AS end_at, r.reviewed_at - r.assigned_at AS duration
+            FROM reviewed_assignments r
+            JOIN users u ON u.user_id = r.user_id
+            WHERE r.assigned_at IS NOT NULL
+            UNION ALL
+            SELECT u.user_id, u.team_name, 'time_to_approval', r.approved_at, r.approved_at - r.assigned_at
+            FROM reviewed_assignments r
+            JOIN users u ON u.user_id = r.user_id
+            WHERE r.assigned_at IS NOT NULL AND r.approved_at IS NOT NULL
+            UNION ALL
+            SELECT u.user_id, u.team_name, 'time_to_merge', m.merged_at, m.merged_at - a.assigned_at
+            FROM last_assignments a
+            JOIN ends m ON m.pull_request_id = a.pull_request_id
+            JOIN users u ON u.user_id = a.user_id
+            WHERE a.event_type = 'reviewer_assigned' AND m.merged_at IS NOT NULL
+        )`,
+}
+
+// GetTurnaround - медиана и p90 длительностей по истории PR (pr_events). Строки с пустым
+// bucket_start - итог группы за период, остальные - бакеты date_trunc(filter.Bucket, end_at)
+func (r *PostgresRepository) GetTurnaround(ctx context.Context, filter models.TurnaroundFilter) ([]models.TurnaroundRow, error) {
+	bucketColumn, grouping := "NULL::timestamp", "GROUP BY group_key, metric"
+	if filter.Bucket != "" {
+		bucketColumn = "date_trunc($4, end_at)"
+		grouping = "GROUP BY GROUPING SETS ((group_key, metric), (group_key, metric, bucket_start))"
+	}
+
+	query := fmt.Sprintf(`
+        WITH bounds AS (
+            SELECT COALESCE($1::timestamp, '-infinity') AS from_ts,
+                   COALESCE($2::timestamp, 'infinity') AS to_ts
+        ),
+        created AS (
+            SELECT pull_request_id, MIN(created_at) AS created_at
+            FROM pr_events
+            WHERE event_type = 'created'
+            GROUP BY pull_request_id
+        ),
+        %s,
+        %s,
+        windowed AS (
+            SELECT s.group_key, s.metric, %s AS bucket_start,
+                EXTRACT(EPOCH FROM s.duration)::double precision AS seconds
+            FROM samples s
+            CROSS JOIN bounds b
+            WHERE s.end_at >= b.from_ts AND s.end_at < b.to_ts
+            AND ($3 = '' OR s.team_name = $3)
+        )
+        SELECT group_key, metric, bucket_start,
+            COUNT(*) AS count,
+            percentile_cont(0.5) WITHIN GROUP (ORDER BY seconds) AS median_seconds,
+            percentile_cont(0.9) WITHIN GROUP (ORDER BY seconds) AS p90_seconds
+        FROM windowed
+        %s
+        ORDER BY group_key, metric, bucket_start NULLS FIRST
+    `, prEndsCTE, turnaroundSamples[filter.GroupBy], bucketColumn, grouping)
+
+	args := []interface{}{filter.From, filter.To, filter.TeamName}
+	if filter.Bucket != "" {
+		args = append(args, filter.Bucket)
+	}
+
+	var rows []models.TurnaroundRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("failed to get turnaround: %w", err)
+	}
+
+	return rows, nil
+}
+
+// GetTeamWorkloads - открытые PR и активные участники по каждой команде
+func (r *PostgresRepository) GetTeamWorkloads(ctx context.Context) ([]models.TeamWorkload, error) {
+	var workloads []models.TeamWorkload
+
+	err := r.db.SelectContext(ctx, &workloads, `
+        SELECT 
+            t.team_name,
+            (SELECT COUNT(*)
+             FROM pull_requests pr
+             JOIN users u ON u.user_id = pr.author_id
+             WHERE u.team_name = t.team_name AND pr.status = 'OPEN'
+            ) AS open_prs,
+            (SELECT COUNT(*) FROM users u WHERE u.team_name = t.team_name AND u.is_active) AS active_reviewers
+        FROM teams t
+        ORDER BY t.team_name
+    `)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team workloads: %w", err)
+	}
+
+	return workloads, nil
+}
+
 func (r *PostgresRepository) GetUsersByTeam(ctx context.Context, teamName string) ([]*models.User, error) {
 	var users []*models.User
 
@@ -290,3 +959,437 @@ func (r *PostgresRepository) GetUsersByTeam(ctx context.Context, teamName string
 
 	return users, nil
 }
+
+// ListUsers - справочник пользователей по user_id с keyset-пагинацией
+func (r *PostgresRepository) ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error) {
+	users := []models.User{}
+
+	err := r.db.SelectContext(ctx, &users, `
+        SELECT user_id, username, team_name, is_active
+        FROM users 
+        WHERE ($1 = '' OR team_name = $1)
+        AND ($2::boolean IS NULL OR is_active = $2)
+        AND user_id > $3
+        ORDER BY user_id
+        LIMIT NULLIF($4, 0)
+    `, filter.TeamName, filter.IsActive, filter.AfterID, filter.Limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (r *PostgresRepository) UpdateUser(ctx context.Context, userID, username, teamName string) (*models.User, error) {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE users 
+        SET username = $1, team_name = $2 
+        WHERE user_id = $3
+    `, username, teamName, userID)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return r.GetUser(ctx, userID)
+}
+
+// GetUsersByIDs - пакетная выборка пользователей; отсутствующие ID просто не попадают в результат
+func (r *PostgresRepository) GetUsersByIDs(ctx context.Context, userIDs []string) ([]*models.User, error) {
+	var users []*models.User
+
+	err := r.db.SelectContext(ctx, &users, `
+        SELECT user_id, username, team_name, is_active
+        FROM users 
+        WHERE user_id = ANY($1)
+        ORDER BY user_id
+    `, pq.Array(userIDs))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users by ids: %w", err)
+	}
+
+	return users, nil
+}
+
+// GetTeamsByNames - пакетный аналог GetTeam: команды вместе с участниками за два запроса
+func (r *PostgresRepository) GetTeamsByNames(ctx context.Context, teamNames []string) ([]models.Team, error) {
+	var nodes []models.TeamNode
+
+	err := r.db.SelectContext(ctx, &nodes, `
+        SELECT team_name, COALESCE(parent_team, '') AS parent_team
+        FROM teams 
+        WHERE team_name = ANY($1)
+        ORDER BY team_name
+    `, pq.Array(teamNames))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get teams: %w", err)
+	}
+
+	var users []models.User
+	err = r.db.SelectContext(ctx, &users, `
+        SELECT user_id, username, team_name, is_active
+        FROM users 
+        WHERE team_name = ANY($1)
+        ORDER BY user_id
+    `, pq.Array(teamNames))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get team members: %w", err)
+	}
+
+	members := make(map[string][]models.TeamMember)
+	for _, u := range users {
+		members[u.TeamName] = append(members[u.TeamName], models.TeamMember{
+			UserID:   u.UserID,
+			Username: u.Username,
+			IsActive: u.IsActive,
+		})
+	}
+
+	teams := make([]models.Team, 0, len(nodes))
+	for _, node := range nodes {
+		team := models.Team{
+			TeamName:   node.TeamName,
+			ParentTeam: node.ParentTeam,
+			Members:    members[node.TeamName],
+		}
+		if team.Members == nil {
+			team.Members = []models.TeamMember{}
+		}
+		teams = append(teams, team)
+	}
+
+	return teams, nil
+}
+
+// GetChildTeams возвращает непосредственных потомков каждой из команд
+func (r *PostgresRepository) GetChildTeams(ctx context.Context, teamNames []string) ([]models.TeamNode, error) {
+	var nodes []models.TeamNode
+
+	err := r.db.SelectContext(ctx, &nodes, `
+        SELECT team_name, parent_team
+        FROM teams 
+        WHERE parent_team = ANY($1)
+        ORDER BY team_name
+    `, pq.Array(teamNames))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get child teams: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// apiKeyRow - scopes хранятся в TEXT[], который sqlx сам в []string не сканирует
+type apiKeyRow struct {
+	models.APIKey
+	ScopesArray pq.StringArray `db:"scopes"`
+}
+
+func (row *apiKeyRow) toModel() models.APIKey {
+	key := row.APIKey
+	key.Scopes = []string(row.ScopesArray)
+	if key.Scopes == nil {
+		key.Scopes = []string{}
+	}
+	return key
+}
+
+const apiKeyColumns = `
+            k.key_id,
+            k.name,
+            k.role,
+            k.scopes,
+            COALESCE(k.user_id, '') AS user_id,
+            COALESCE(u.team_name, '') AS team_name,
+            k.created_at,
+            k.expires_at,
+            k.last_used_at,
+            k.revoked_at
+        FROM api_keys k
+        LEFT JOIN users u ON u.user_id = k.user_id`
+
+// CreateAPIKey создаёт ключ; повторный вызов с тем же хешем ничего не меняет,
+// чтобы bootstrap-ключ из окружения можно было заводить при каждом старте
+func (r *PostgresRepository) CreateAPIKey(ctx context.Context, key *models.APIKey, keyHash string) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO api_keys (key_id, key_hash, name, role, scopes, user_id, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, $8)
+        ON CONFLICT (key_hash) DO NOTHING
+    `, key.KeyID, keyHash, key.Name, key.Role, pq.Array(key.Scopes), key.UserID, key.CreatedAt, key.ExpiresAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var row apiKeyRow
+	err := r.db.GetContext(ctx, &row, `SELECT`+apiKeyColumns+`
+        WHERE k.key_hash = $1
+    `, keyHash)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	key := row.toModel()
+	return &key, nil
+}
+
+func (r *PostgresRepository) GetAPIKey(ctx context.Context, keyID string) (*models.APIKey, error) {
+	var row apiKeyRow
+	err := r.db.GetContext(ctx, &row, `SELECT`+apiKeyColumns+`
+        WHERE k.key_id = $1
+    `, keyID)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	key := row.toModel()
+	return &key, nil
+}
+
+func (r *PostgresRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var rows []apiKeyRow
+	err := r.db.SelectContext(ctx, &rows, `SELECT`+apiKeyColumns+`
+        ORDER BY k.created_at DESC, k.key_id
+    `)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+
+	keys := make([]models.APIKey, 0, len(rows))
+	for i := range rows {
+		keys = append(keys, rows[i].toModel())
+	}
+
+	return keys, nil
+}
+
+func (r *PostgresRepository) RevokeAPIKey(ctx context.Context, keyID string, revokedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE api_keys 
+        SET revoked_at = $1 
+        WHERE key_id = $2 AND revoked_at IS NULL
+    `, revokedAt, keyID)
+
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	return nil
+}
+
+// TouchAPIKey обновляет last_used_at не чаще раза в минуту, чтобы не писать в БД на каждый запрос
+func (r *PostgresRepository) TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+        UPDATE api_keys 
+        SET last_used_at = $1 
+        WHERE key_id = $2
+        AND (last_used_at IS NULL OR last_used_at < $1 - INTERVAL '1 minute')
+    `, usedAt, keyID)
+
+	if err != nil {
+		return fmt.Errorf("failed to update api key usage: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO audit_log (actor, action, target_type, target_id, before, after, request_id, created_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id
+    `, entry.Actor, entry.Action, entry.TargetType, entry.TargetID,
+		nullableJSON(entry.Before), nullableJSON(entry.After), entry.RequestID, entry.CreatedAt,
+	).Scan(&entry.ID)
+
+	if err != nil {
+		return fmt.Errorf("failed to insert audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// auditRow: NULL из JSONB сканируется только в []byte, но не в json.RawMessage
+type auditRow struct {
+	models.AuditEntry
+	BeforeJSON []byte `db:"before"`
+	AfterJSON  []byte `db:"after"`
+}
+
+// ListAuditEntries отдаёт записи от новых к старым
+func (r *PostgresRepository) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	var rows []auditRow
+
+	err := r.db.SelectContext(ctx, &rows, `
+        SELECT id, actor, action, target_type, target_id, before, after, request_id, created_at
+        FROM audit_log
+        WHERE ($1 = '' OR actor = $1)
+        AND ($2 = '' OR action = $2)
+        AND ($3 = '' OR target_type = $3)
+        AND ($4 = '' OR target_id = $4)
+        AND ($5::timestamp IS NULL OR created_at >= $5)
+        AND ($6::timestamp IS NULL OR created_at < $6)
+        AND ($7 = 0 OR id < $7)
+        ORDER BY id DESC
+        LIMIT $8
+    `, filter.Actor, filter.Action, filter.TargetType, filter.TargetID,
+		filter.From, filter.To, filter.BeforeID, filter.Limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+
+	entries := make([]models.AuditEntry, 0, len(rows))
+	for _, row := range rows {
+		entry := row.AuditEntry
+		entry.Before = row.BeforeJSON
+		entry.After = row.AfterJSON
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// ReserveIdempotencyKey занимает ключ под новый запрос. Перезаписываются истёкшая запись и
+// резервация без ответа, созданная раньше staleBefore; false - ключ занят действующей записью
+func (r *PostgresRepository) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+        INSERT INTO idempotency_keys (scope, idempotency_key, request_hash, created_at, expires_at)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (scope, idempotency_key) DO UPDATE
+        SET request_hash = EXCLUDED.request_hash,
+            status_code = NULL,
+            content_type = NULL,
+            response_body = NULL,
+            response_headers = NULL,
+            created_at = EXCLUDED.created_at,
+            expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at < EXCLUDED.created_at
+           OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $6)
+    `, record.Scope, record.Key, record.RequestHash, record.CreatedAt, record.ExpiresAt, staleBefore)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// idempotencyRow: заголовки ответа хранятся в JSONB, NULL - заголовков нет
+type idempotencyRow struct {
+	models.IdempotencyRecord
+	HeadersJSON []byte `db:"response_headers"`
+}
+
+func (r *PostgresRepository) GetIdempotencyKey(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	var row idempotencyRow
+	err := r.db.GetContext(ctx, &row, `
+        SELECT scope, idempotency_key, request_hash, COALESCE(status_code, 0) AS status_code,
+               COALESCE(content_type, '') AS content_type, response_body, response_headers,
+               created_at, expires_at
+        FROM idempotency_keys
+        WHERE scope = $1 AND idempotency_key = $2
+    `, scope, key)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+
+	record := row.IdempotencyRecord
+	if len(row.HeadersJSON) > 0 {
+		if err := json.Unmarshal(row.HeadersJSON, &record.ResponseHeaders); err != nil {
+			return nil, fmt.Errorf("failed to decode idempotent response headers: %w", err)
+		}
+	}
+	return &record, nil
+}
+
+// CompleteIdempotencyKey сохраняет ответ в резервацию, созданную этим же запросом: её узнают
+// по request_hash и created_at. false - резервацию уже перехватил повтор после lease
+func (r *PostgresRepository) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	var headers json.RawMessage
+	if len(record.ResponseHeaders) > 0 {
+		var err error
+		if headers, err = json.Marshal(record.ResponseHeaders); err != nil {
+			return false, fmt.Errorf("failed to encode idempotent response headers: %w", err)
+		}
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+        UPDATE idempotency_keys
+        SET status_code = $1, content_type = $2, response_body = $3, response_headers = $4
+        WHERE scope = $5 AND idempotency_key = $6
+        AND request_hash = $7 AND created_at = $8 AND status_code IS NULL
+    `, record.StatusCode, record.ContentType, record.ResponseBody, nullableJSON(headers),
+		record.Scope, record.Key, record.RequestHash, record.CreatedAt)
+
+	if err != nil {
+		return false, fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// DeleteIdempotencyKey снимает резервацию без ответа; перехваченную другим запросом не трогает
+func (r *PostgresRepository) DeleteIdempotencyKey(ctx context.Context, reservation *models.IdempotencyRecord) error {
+	_, err := r.db.ExecContext(ctx, `
+        DELETE FROM idempotency_keys
+        WHERE scope = $1 AND idempotency_key = $2
+        AND request_hash = $3 AND created_at = $4 AND status_code IS NULL
+    `, reservation.Scope, reservation.Key, reservation.RequestHash, reservation.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to delete idempotency key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *PostgresRepository) PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `
+        DELETE FROM idempotency_keys
+        WHERE expires_at < $1
+    `, before)
+
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// nullableJSON превращает пустой json.RawMessage в NULL, а не в пустую строку
+func nullableJSON(data json.RawMessage) interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	return []byte(data)
+}
@@ -11,30 +11,78 @@ type TeamRepository interface {
 	CreateTeam(ctx context.Context, team *models.Team) error
 	GetTeam(ctx context.Context, teamName string) (*models.Team, error)
 	TeamExists(ctx context.Context, teamName string) (bool, error)
+	SetTeamParent(ctx context.Context, teamName, parentTeam string) error
+	GetTeamAncestors(ctx context.Context, teamName string) ([]string, error)
+	GetTeamSubtree(ctx context.Context, teamName string) ([]models.TeamNode, error)
+	GetTeamsByNames(ctx context.Context, teamNames []string) ([]models.Team, error)
+	GetChildTeams(ctx context.Context, teamNames []string) ([]models.TeamNode, error)
 }
 
 type UserRepository interface {
 	UpdateUserActivity(ctx context.Context, userID string, isActive bool) (*models.User, error)
 	GetUser(ctx context.Context, userID string) (*models.User, error)
 	GetUsersByTeam(ctx context.Context, teamName string) ([]*models.User, error)
+	GetUsersByIDs(ctx context.Context, userIDs []string) ([]*models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, error)
+	UpdateUser(ctx context.Context, userID, username, teamName string) (*models.User, error)
 }
 
 type PRRepository interface {
 	CreatePR(ctx context.Context, pr *models.PullRequest) error
 	GetPR(ctx context.Context, prID string) (*models.PullRequest, error)
-	UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus, mergedAt *time.Time) error
-	UpdatePRReviewers(ctx context.Context, prID string, reviewers []string) error
-	GetPRsByReviewer(ctx context.Context, userID string) ([]models.PullRequestShort, error)
+	UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus, mergedAt *time.Time, expectedVersion int64) (int64, error)
+	UpdatePRReviewers(ctx context.Context, prID string, reviewers []string, expectedVersion int64) (int64, error)
+	GetPRsByReviewer(ctx context.Context, userID string, filter models.ReviewFilter) ([]models.PullRequestShort, error)
+	GetOpenPRsByReviewers(ctx context.Context, userIDs []string) (map[string][]models.PullRequest, error)
+	ListPRs(ctx context.Context, filter models.PRListFilter) ([]models.PullRequest, error)
+	StreamPRs(ctx context.Context, filter models.PRListFilter, fn func(models.PullRequest) error) error
 	PRExists(ctx context.Context, prID string) (bool, error)
+	AddPREvents(ctx context.Context, events []models.PREvent) error
+	GetPREvents(ctx context.Context, prID string) ([]models.PREvent, error)
+	StreamPREvents(ctx context.Context, filter models.PREventFilter, fn func(models.PREvent) error) error
 }
 
 type ReviewStat interface {
-	GetReviewStats(ctx context.Context) ([]models.ReviewStat, error)
+	GetReviewStats(ctx context.Context, teamNames []string, window models.TimeWindow, after *models.StatCursor, limit int) ([]models.ReviewStat, error)
+	StreamReviewStats(ctx context.Context, teamNames []string, window models.TimeWindow, after *models.StatCursor, limit int, fn func(models.ReviewStat) error) error
+	GetTeamReviewCounts(ctx context.Context, teamNames []string, window models.TimeWindow) ([]models.TeamReviewStat, error)
+	GetTurnaround(ctx context.Context, filter models.TurnaroundFilter) ([]models.TurnaroundRow, error)
+	GetReviewPairs(ctx context.Context, filter models.PairFilter) ([]models.ReviewPair, error)
+	GetTeamWorkloads(ctx context.Context) ([]models.TeamWorkload, error)
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey, keyHash string) error
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	GetAPIKey(ctx context.Context, keyID string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string, revokedAt time.Time) error
+	TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error
+}
+
+type AuditRepository interface {
+	InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type IdempotencyRepository interface {
+	ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (bool, error)
+	GetIdempotencyKey(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (bool, error)
+	DeleteIdempotencyKey(ctx context.Context, reservation *models.IdempotencyRecord) error
+	PurgeIdempotencyKeys(ctx context.Context, before time.Time) (int64, error)
 }
 
 type Repository interface {
+	// WithTx выполняет fn в одной транзакции: изменения, сделанные через tx,
+	// фиксируются вместе, если fn вернула nil, и откатываются иначе
+	WithTx(ctx context.Context, fn func(tx Repository) error) error
+
 	TeamRepository
 	UserRepository
 	PRRepository
 	ReviewStat
+	APIKeyRepository
+	AuditRepository
+	IdempotencyRepository
 }
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/requestid"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+const (
+	AuditTeamCreate         = "team.create"
+	AuditTeamSetParent      = "team.set_parent"
+	AuditUserUpsert         = "user.upsert"
+	AuditUserSetActivity    = "user.set_activity"
+	AuditUserUpdate         = "user.update"
+	AuditUserBulkDeactivate = "user.bulk_deactivate"
+	AuditPRCreate           = "pr.create"
+	AuditPRMerge            = "pr.merge"
+	AuditPRClose            = "pr.close"
+	AuditPRReview           = "pr.review"
+	AuditPRReassign         = "pr.reassign"
+	AuditPRBulkReassign     = "pr.bulk_reassign"
+	AuditAPIKeyCreate       = "apikey.create"
+	AuditAPIKeyRevoke       = "apikey.revoke"
+)
+
+const (
+	auditTargetTeam   = "team"
+	auditTargetUser   = "user"
+	auditTargetPR     = "pull_request"
+	auditTargetAPIKey = "api_key"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// actor - кто выполняет операцию: ключ, пользователь из OIDC или сам сервис
+func actor(ctx context.Context) string {
+	principal := auth.PrincipalFromContext(ctx)
+	switch {
+	case principal == nil:
+		return "system"
+	case principal.KeyID != "":
+		return "key:" + principal.KeyID
+	default:
+		return "user:" + principal.UserID
+	}
+}
+
+// audit дописывает запись в журнал; before/after - снимки объекта, nil - объекта не было.
+// Вызывается внутри inTx, чтобы запись фиксировалась вместе с изменением
+func (s *Service) audit(ctx context.Context, action, targetType, targetID string, before, after interface{}) error {
+	entry := &models.AuditEntry{
+		Actor:      actor(ctx),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		RequestID:  requestid.FromContext(ctx),
+		CreatedAt:  time.Now(),
+	}
+
+	var err error
+	if entry.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	return s.repo.InsertAuditEntry(ctx, entry)
+}
+
+func snapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal audit snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// ListAuditEntries возвращает страницу журнала и курсор следующей (0 - страниц больше нет)
+func (s *Service) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, int64, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListAuditEntries")
+	defer span.End()
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditLimit
+	}
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	limit := filter.Limit
+	filter.Limit++
+	entries, err := s.repo.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var next int64
+	if len(entries) > limit {
+		entries = entries[:limit]
+		next = entries[limit-1].ID
+	}
+
+	return entries, next, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+// Пакетные чтения для GraphQL-загрузчиков: один запрос в репозиторий на весь
+// набор ключей вместо запроса на каждый узел графа
+
+func (s *Service) GetUsersByIDs(ctx context.Context, userIDs []string) ([]*models.User, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetUsersByIDs", tracing.Users(userIDs))
+	defer span.End()
+
+	return s.repo.GetUsersByIDs(ctx, userIDs)
+}
+
+func (s *Service) GetTeamsByNames(ctx context.Context, teamNames []string) ([]models.Team, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetTeamsByNames", tracing.Teams(teamNames))
+	defer span.End()
+
+	return s.repo.GetTeamsByNames(ctx, teamNames)
+}
+
+// GetChildTeams возвращает имена непосредственных потомков для каждой команды
+func (s *Service) GetChildTeams(ctx context.Context, teamNames []string) (map[string][]string, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetChildTeams", tracing.Teams(teamNames))
+	defer span.End()
+
+	nodes, err := s.repo.GetChildTeams(ctx, teamNames)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]string, len(teamNames))
+	for _, node := range nodes {
+		children[node.ParentTeam] = append(children[node.ParentTeam], node.TeamName)
+	}
+	return children, nil
+}
+
+// GetPendingReviews - открытые PR, назначенные на каждого из пользователей
+func (s *Service) GetPendingReviews(ctx context.Context, userIDs []string) (map[string][]models.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPendingReviews", tracing.Users(userIDs))
+	defer span.End()
+
+	return s.repo.GetOpenPRsByReviewers(ctx, userIDs)
+}
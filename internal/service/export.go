@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+// Выгрузки читают строки курсором базы и отдают их в fn по одной, не собирая в память.
+// Пагинации нет: выгружается весь диапазон фильтра
+
+// ExportPRs выгружает PR по фильтрам листинга; курсор и limit не используются
+func (s *Service) ExportPRs(ctx context.Context, filter models.PRListFilter, fn func(models.PullRequest) error) error {
+	ctx, span := tracing.Start(ctx, "Service.ExportPRs", tracing.Team(filter.TeamName))
+	defer span.End()
+
+	if err := preparePRFilter(&filter.ReviewFilter, ""); err != nil {
+		return err
+	}
+	filter.Limit = 0
+	return s.repo.StreamPRs(ctx, filter, fn)
+}
+
+// ExportAssignments выгружает историю назначений и снятий ревьюверов за период,
+// team - команда автора PR
+func (s *Service) ExportAssignments(ctx context.Context, window models.TimeWindow, teamName string, fn func(models.PREvent) error) error {
+	ctx, span := tracing.Start(ctx, "Service.ExportAssignments", tracing.Team(teamName))
+	defer span.End()
+
+	if err := validateWindow(window); err != nil {
+		return err
+	}
+	if err := s.requireTeam(ctx, teamName); err != nil {
+		return err
+	}
+
+	filter := models.PREventFilter{
+		TimeWindow: window,
+		TeamName:   teamName,
+		EventTypes: []string{string(models.PREventReviewerAssigned), string(models.PREventReviewerUnassigned)},
+	}
+	return s.repo.StreamPREvents(ctx, filter, fn)
+}
+
+// ExportReviewStats выгружает статистику ревьюверов целиком, без курсора
+func (s *Service) ExportReviewStats(ctx context.Context, filter models.ReviewStatsFilter, fn func(models.ReviewStat) error) error {
+	ctx, span := tracing.Start(ctx, "Service.ExportReviewStats", tracing.Team(filter.TeamName))
+	defer span.End()
+
+	if err := validateWindow(filter.TimeWindow); err != nil {
+		return err
+	}
+
+	var teamNames []string
+	if filter.TeamName != "" {
+		if err := s.requireTeam(ctx, filter.TeamName); err != nil {
+			return err
+		}
+		var err error
+		if teamNames, _, err = s.statsTeams(ctx, filter.TeamName, filter.Subtree); err != nil {
+			return err
+		}
+	}
+	return s.repo.StreamReviewStats(ctx, teamNames, filter.TimeWindow, nil, 0, fn)
+}
+
+// requireTeam: пустое имя - без фильтра, иначе команда должна существовать
+func (s *Service) requireTeam(ctx context.Context, teamName string) error {
+	if teamName == "" {
+		return nil
+	}
+	exists, err := s.repo.TeamExists(ctx, teamName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
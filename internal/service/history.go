@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+// recordPREvents сохраняет события истории PR от имени текущего actor. Вызывается
+// внутри inTx: статистика и выгрузки строятся по событиям, поэтому они не должны
+// расходиться с pull_requests
+func (s *Service) recordPREvents(ctx context.Context, events ...models.PREvent) error {
+	now := time.Now()
+	who := actor(ctx)
+	for i := range events {
+		events[i].Actor = who
+		if events[i].CreatedAt.IsZero() {
+			events[i].CreatedAt = now
+		}
+	}
+	return s.repo.AddPREvents(ctx, events)
+}
+
+// reassignmentEvents - снятие старого ревьювера и назначение нового с общей причиной
+func reassignmentEvents(prID, oldUserID, newUserID string, cause models.AssignmentCause) []models.PREvent {
+	return []models.PREvent{
+		{PullRequestID: prID, EventType: models.PREventReviewerUnassigned, UserID: oldUserID, Cause: cause},
+		{PullRequestID: prID, EventType: models.PREventReviewerAssigned, UserID: newUserID, Cause: cause},
+	}
+}
+
+// GetPRHistory возвращает хронологию PR из сохранённых событий
+func (s *Service) GetPRHistory(ctx context.Context, prID string) ([]models.PREvent, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPRHistory", tracing.PRID(prID))
+	defer span.End()
+
+	exists, err := s.repo.PRExists(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	return s.repo.GetPREvents(ctx, prID)
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+const (
+	// defaultIdempotencyTTL - сколько хранится ответ, если WithIdempotencyTTL не задан
+	defaultIdempotencyTTL = 24 * time.Hour
+	// defaultIdempotencyLease больше write_timeout по умолчанию: к этому времени клиент
+	// первого запроса уже не ждёт ответа
+	defaultIdempotencyLease = 2 * time.Minute
+)
+
+func WithIdempotencyTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.idempotencyTTL = ttl
+	}
+}
+
+// WithIdempotencyLease задаёт, сколько ключ может оставаться занятым без ответа. Резервацию
+// процесса, упавшего посреди запроса, никто не снимет, поэтому после lease её перехватывает повтор
+func WithIdempotencyLease(lease time.Duration) Option {
+	return func(s *Service) {
+		s.idempotencyLease = lease
+	}
+}
+
+// BeginIdempotentRequest занимает ключ под запрос с хешем requestHash. Если такой же
+// запрос уже выполнен, возвращается сохранённый ответ (StatusCode != 0) - его нужно отдать
+// клиенту вместо повторного выполнения. Иначе возвращается резервация: по ней запрос
+// потом сохраняет ответ или освобождает ключ
+func (s *Service) BeginIdempotentRequest(ctx context.Context, key, requestHash string) (*models.IdempotencyRecord, error) {
+	ctx, span := tracing.Start(ctx, "Service.BeginIdempotentRequest")
+	defer span.End()
+
+	// created_at вместе с хешем отличает резервацию от перехватившего её повтора;
+	// TIMESTAMP хранит микросекунды, поэтому время сразу обрезается до них
+	now := time.Now().Truncate(time.Microsecond)
+	reservation := &models.IdempotencyRecord{
+		Scope:       actor(ctx),
+		Key:         key,
+		RequestHash: requestHash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(s.idempotencyTTL),
+	}
+	reserved, err := s.repo.ReserveIdempotencyKey(ctx, reservation, now.Add(-s.idempotencyLease))
+	if err != nil {
+		return nil, err
+	}
+	if reserved {
+		return reservation, nil
+	}
+
+	record, err := s.repo.GetIdempotencyKey(ctx, actor(ctx), key)
+	if err != nil {
+		return nil, err
+	}
+	// другой запрос с тем же ключом - ошибка клиента, даже пока первый ещё выполняется
+	if record != nil && record.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	// запись могли удалить между двумя запросами - первый запрос завершился ошибкой
+	if record == nil || record.StatusCode == 0 {
+		return nil, ErrIdempotencyInFlight
+	}
+	return record, nil
+}
+
+// CompleteIdempotentRequest сохраняет ответ в резервацию из BeginIdempotentRequest.
+// ErrIdempotencyLeaseLost - запрос выполнялся дольше lease и ключ уже занял повтор
+func (s *Service) CompleteIdempotentRequest(ctx context.Context, reservation *models.IdempotencyRecord, statusCode int, contentType string, headers map[string]string, body []byte) error {
+	ctx, span := tracing.Start(ctx, "Service.CompleteIdempotentRequest")
+	defer span.End()
+
+	record := *reservation
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseHeaders = headers
+	record.ResponseBody = body
+	stored, err := s.repo.CompleteIdempotencyKey(ctx, &record)
+	if err != nil {
+		return err
+	}
+	if !stored {
+		return ErrIdempotencyLeaseLost
+	}
+	return nil
+}
+
+// AbortIdempotentRequest освобождает ключ, чтобы повтор выполнил запрос заново
+// (например, после внутренней ошибки)
+func (s *Service) AbortIdempotentRequest(ctx context.Context, reservation *models.IdempotencyRecord) error {
+	ctx, span := tracing.Start(ctx, "Service.AbortIdempotentRequest")
+	defer span.End()
+
+	return s.repo.DeleteIdempotencyKey(ctx, reservation)
+}
+
+// PurgeIdempotencyKeys удаляет истёкшие ключи; вызывается фоновым воркером по расписанию
+func (s *Service) PurgeIdempotencyKeys(ctx context.Context) error {
+	purged, err := s.repo.PurgeIdempotencyKeys(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	if purged > 0 {
+		slog.InfoContext(ctx, "purged expired idempotency keys", "count", purged)
+	}
+	return nil
+}
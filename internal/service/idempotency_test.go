@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/repository"
+)
+
+// idempotencyRepo хранит ключи в памяти по тем же правилам, что и ReserveIdempotencyKey в Postgres
+type idempotencyRepo struct {
+	repository.Repository
+	records map[string]*models.IdempotencyRecord
+}
+
+func (r *idempotencyRepo) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	existing, ok := r.records[record.Key]
+	if ok && !existing.ExpiresAt.Before(record.CreatedAt) &&
+		(existing.StatusCode != 0 || !existing.CreatedAt.Before(staleBefore)) {
+		return false, nil
+	}
+	stored := *record
+	r.records[record.Key] = &stored
+	return true, nil
+}
+
+func (r *idempotencyRepo) GetIdempotencyKey(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	return r.records[key], nil
+}
+
+func (r *idempotencyRepo) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	existing, ok := r.records[record.Key]
+	if !ok || existing.StatusCode != 0 || existing.RequestHash != record.RequestHash || !existing.CreatedAt.Equal(record.CreatedAt) {
+		return false, nil
+	}
+	stored := *record
+	r.records[record.Key] = &stored
+	return true, nil
+}
+
+func TestBeginIdempotentRequest(t *testing.T) {
+	ctx := context.Background()
+	repo := &idempotencyRepo{records: map[string]*models.IdempotencyRecord{}}
+	s := NewService(repo, WithIdempotencyLease(time.Minute))
+
+	reservation, err := s.BeginIdempotentRequest(ctx, "k1", "hash-a")
+	require.NoError(t, err)
+	assert.Zero(t, reservation.StatusCode, "новый ключ резервируется под запрос")
+
+	_, err = s.BeginIdempotentRequest(ctx, "k1", "hash-a")
+	assert.ErrorIs(t, err, ErrIdempotencyInFlight)
+
+	_, err = s.BeginIdempotentRequest(ctx, "k1", "hash-b")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused, "другое тело отклоняется и пока первый запрос выполняется")
+
+	require.NoError(t, s.CompleteIdempotentRequest(ctx, reservation, 201, "application/json", nil, []byte(`{"ok":true}`)))
+	stored, err := s.BeginIdempotentRequest(ctx, "k1", "hash-a")
+	require.NoError(t, err)
+	assert.Equal(t, 201, stored.StatusCode)
+
+	_, err = s.BeginIdempotentRequest(ctx, "k1", "hash-b")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+}
+
+func TestIdempotencyLeaseTakeover(t *testing.T) {
+	ctx := context.Background()
+	repo := &idempotencyRepo{records: map[string]*models.IdempotencyRecord{}}
+	s := NewService(repo, WithIdempotencyLease(time.Minute))
+
+	// медленный первый запрос ещё выполняется, когда его lease истекает
+	slow, err := s.BeginIdempotentRequest(ctx, "k1", "hash-a")
+	require.NoError(t, err)
+	repo.records["k1"].CreatedAt = time.Now().Add(-2 * time.Minute)
+
+	retry, err := s.BeginIdempotentRequest(ctx, "k1", "hash-a")
+	require.NoError(t, err)
+	assert.Zero(t, retry.StatusCode, "повтор перехватывает резервацию старше lease")
+
+	err = s.CompleteIdempotentRequest(ctx, slow, 201, "", nil, []byte("slow"))
+	assert.ErrorIs(t, err, ErrIdempotencyLeaseLost, "перехваченная резервация не перезаписывается")
+
+	// сохранённый ответ не перехватывается, сколько бы ему ни было лет
+	require.NoError(t, s.CompleteIdempotentRequest(ctx, retry, 200, "", nil, []byte("retry")))
+	repo.records["k1"].CreatedAt = time.Now().Add(-2 * time.Minute)
+	stored, err := s.BeginIdempotentRequest(ctx, "k1", "hash-a")
+	require.NoError(t, err)
+	assert.Equal(t, 200, stored.StatusCode)
+	assert.Equal(t, []byte("retry"), stored.ResponseBody)
+}
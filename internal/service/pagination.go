@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// Unpaged - Limit, при котором возвращается вся выборка без next_cursor. Так v1-эндпоинты
+// отвечают клиентам, которые не передают limit и не знают о пагинации
+const Unpaged = -1
+
+func pageLimit(limit int) int {
+	switch {
+	case limit <= 0:
+		return defaultPageLimit
+	case limit > maxPageLimit:
+		return maxPageLimit
+	default:
+		return limit
+	}
+}
+
+// курсоры непрозрачны для клиента: это base64 от JSON с позицией последней записи
+func encodeCursor(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: malformed cursor", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// preparePRFilter проверяет статус и раскрывает курсор в позицию keyset-пагинации
+func preparePRFilter(filter *models.ReviewFilter, cursor string) error {
+	switch filter.Status {
+	case "", models.StatusOpen, models.StatusMerged, models.StatusClosed:
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidRequest, filter.Status)
+	}
+
+	if cursor != "" {
+		var after models.PRCursor
+		if err := decodeCursor(cursor, &after); err != nil {
+			return err
+		}
+		filter.After = &after
+	}
+	return nil
+}
+
+// nextPRCursor - курсор после последнего PR страницы; PR без created_at
+// сортируются как 'epoch', см. GetPRsByReviewer
+func nextPRCursor(createdAt *time.Time, prID string) string {
+	position := models.PRCursor{CreatedAt: time.Unix(0, 0).UTC(), PullRequestID: prID}
+	if createdAt != nil {
+		position.CreatedAt = *createdAt
+	}
+	return encodeCursor(position)
+}
+
+// ListReviews - страница PR ревьювера и курсор следующей ("" - страниц больше нет)
+func (s *Service) ListReviews(ctx context.Context, userID string, filter models.ReviewFilter, cursor string) ([]models.PullRequestShort, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListReviews", tracing.User(userID))
+	defer span.End()
+
+	if err := preparePRFilter(&filter, cursor); err != nil {
+		return nil, "", err
+	}
+	if filter.Limit == Unpaged {
+		filter.Limit = 0
+		prs, err := s.repo.GetPRsByReviewer(ctx, userID, filter)
+		return prs, "", err
+	}
+
+	limit := pageLimit(filter.Limit)
+	filter.Limit = limit + 1
+	prs, err := s.repo.GetPRsByReviewer(ctx, userID, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(prs) > limit {
+		prs = prs[:limit]
+		next = nextPRCursor(prs[limit-1].CreatedAt, prs[limit-1].PullRequestID)
+	}
+
+	return prs, next, nil
+}
+
+// ListPRs - страница листинга PR и курсор следующей
+func (s *Service) ListPRs(ctx context.Context, filter models.PRListFilter, cursor string) ([]models.PullRequest, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListPRs", tracing.Team(filter.TeamName))
+	defer span.End()
+
+	if err := preparePRFilter(&filter.ReviewFilter, cursor); err != nil {
+		return nil, "", err
+	}
+
+	limit := pageLimit(filter.Limit)
+	filter.Limit = limit + 1
+	prs, err := s.repo.ListPRs(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(prs) > limit {
+		prs = prs[:limit]
+		next = nextPRCursor(prs[limit-1].CreatedAt, prs[limit-1].PullRequestID)
+	}
+
+	return prs, next, nil
+}
+
+// ListUsers - страница справочника пользователей и курсор следующей
+func (s *Service) ListUsers(ctx context.Context, filter models.UserFilter, cursor string) ([]models.User, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListUsers")
+	defer span.End()
+
+	if cursor != "" {
+		if err := decodeCursor(cursor, &filter.AfterID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	limit := pageLimit(filter.Limit)
+	filter.Limit = limit + 1
+	users, err := s.repo.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(users) > limit {
+		users = users[:limit]
+		next = encodeCursor(users[limit-1].UserID)
+	}
+
+	return users, next, nil
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// maxConflictRetries - сколько раз операция без If-Match перечитывает PR, если его
+// изменили между чтением и записью
+const maxConflictRetries = 3
+
+// errVersionConflict - UPDATE не нашёл PR в прочитанной версии
+var errVersionConflict = errors.New("PR version changed")
+
+type expectedVersionKey struct{}
+
+// WithExpectedVersion задаёт версию PR, которую видел клиент (If-Match). Изменяющие PR
+// операции с таким контекстом выполняются, только если PR с тех пор не менялся,
+// иначе возвращают ErrPreconditionFailed
+func WithExpectedVersion(ctx context.Context, version int64) context.Context {
+	return context.WithValue(ctx, expectedVersionKey{}, version)
+}
+
+// expectedVersion: 0 - условие не задано
+func expectedVersion(ctx context.Context) int64 {
+	version, _ := ctx.Value(expectedVersionKey{}).(int64)
+	return version
+}
+
+// checkVersion - ранняя проверка по прочитанной версии, чтобы не выбирать ревьюверов зря;
+// окончательно версию проверяет сам UPDATE, который всегда получает прочитанную версию
+func checkVersion(ctx context.Context, current int64) error {
+	if expected := expectedVersion(ctx); expected != 0 && expected != current {
+		return ErrPreconditionFailed
+	}
+	return nil
+}
+
+// retryOnConflict выполняет попытку attempt, которая читает PR и пишет его по прочитанной
+// версии. Если PR успели изменить, попытка повторяется на свежих данных: повторный мерж
+// становится идемпотентным, а переназначение видит мерж или другое переназначение.
+// С If-Match повторять нельзя - клиент просил изменить именно ту версию, которую видел
+func retryOnConflict(ctx context.Context, attempt func() error) error {
+	for i := 1; ; i++ {
+		err := attempt()
+		if !errors.Is(err, errVersionConflict) {
+			return err
+		}
+		if expectedVersion(ctx) != 0 {
+			return ErrPreconditionFailed
+		}
+		if i == maxConflictRetries {
+			return ErrConflict
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/events"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+// Publisher получает живые события после успешных изменений (см. events.Broker)
+type Publisher interface {
+	Publish(e events.Event)
+}
+
+type nopPublisher struct{}
+
+func (nopPublisher) Publish(events.Event) {}
+
+// Metrics считает изменения PR и переназначения без кандидата (см. metrics.Metrics)
+type Metrics interface {
+	PRChanged(action string)
+	NoCandidate(cause models.AssignmentCause)
+}
+
+// действия PR для Metrics.PRChanged
+const (
+	PRActionCreated    = "created"
+	PRActionMerged     = "merged"
+	PRActionReassigned = "reassigned"
+	PRActionClosed     = "closed"
+	PRActionReviewed   = "reviewed"
+)
+
+type nopMetrics struct{}
+
+func (nopMetrics) PRChanged(string)                   {}
+func (nopMetrics) NoCandidate(models.AssignmentCause) {}
+
+type Option func(*Service)
+
+func WithPublisher(p Publisher) Option {
+	return func(s *Service) {
+		s.publisher = p
+	}
+}
+
+func WithMetrics(m Metrics) Option {
+	return func(s *Service) {
+		s.metrics = m
+	}
+}
+
+// userTeam - команда пользователя для фильтрации событий; публикация не должна
+// ломать уже выполненную операцию, поэтому ошибки здесь не возвращаются
+func (s *Service) userTeam(ctx context.Context, userID string) string {
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil || user == nil {
+		return ""
+	}
+	return user.TeamName
+}
+
+func reassignedEvent(teamName, prID, oldUserID, newUserID string, cause models.AssignmentCause) events.Event {
+	return events.Event{
+		Type:          events.TypeReviewerReassigned,
+		TeamName:      teamName,
+		PullRequestID: prID,
+		UserIDs:       []string{oldUserID, newUserID},
+		Data: map[string]string{
+			"old_user_id": oldUserID,
+			"new_user_id": newUserID,
+			"cause":       string(cause),
+		},
+	}
+}
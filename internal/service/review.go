@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/events"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+// SubmitReview записывает решение назначенного ревьювера по открытому PR.
+// Участник (роль member) отправляет решение только от своего имени; пустой
+// reviewerID - сам вызывающий. Ключи ботов и тимлидов могут переносить решения
+// из внешней системы за любого ревьювера
+func (s *Service) SubmitReview(ctx context.Context, prID, reviewerID string, verdict models.ReviewVerdict) (*models.PREvent, error) {
+	ctx, span := tracing.Start(ctx, "Service.SubmitReview", tracing.PRID(prID), tracing.User(reviewerID))
+	defer span.End()
+
+	principal := auth.PrincipalFromContext(ctx)
+	if reviewerID == "" && principal != nil {
+		reviewerID = principal.UserID
+	}
+	if reviewerID == "" {
+		return nil, fmt.Errorf("%w: reviewer_id is required", ErrInvalidRequest)
+	}
+	if !verdict.Valid() {
+		return nil, fmt.Errorf("%w: verdict must be approved, changes_requested or commented", ErrInvalidRequest)
+	}
+	if principal != nil && principal.Role == auth.RoleMember && principal.UserID != reviewerID {
+		return nil, ErrForbidden
+	}
+
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, ErrNotFound
+	}
+	switch pr.Status {
+	case models.StatusMerged:
+		return nil, ErrPRMerged
+	case models.StatusClosed:
+		return nil, ErrPRClosed
+	}
+	if !s.contains(pr.AssignedReviewers, reviewerID) {
+		return nil, ErrNotAssigned
+	}
+
+	event := models.PREvent{
+		PullRequestID: prID,
+		EventType:     models.PREventReviewSubmitted,
+		UserID:        reviewerID,
+		Verdict:       verdict,
+		CreatedAt:     time.Now(),
+	}
+	err = s.inTx(ctx, func(tx *Service) error {
+		// recordPREvents дописывает ID и actor в переданный срез
+		recorded := []models.PREvent{event}
+		if err := tx.recordPREvents(ctx, recorded...); err != nil {
+			return err
+		}
+		event = recorded[0]
+		return tx.audit(ctx, AuditPRReview, auditTargetPR, prID, nil, event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.metrics.PRChanged(PRActionReviewed)
+	s.publisher.Publish(events.Event{
+		Type:          events.TypeReviewSubmitted,
+		TeamName:      s.userTeam(ctx, pr.AuthorID),
+		PullRequestID: prID,
+		UserIDs:       []string{pr.AuthorID, reviewerID},
+		Data:          event,
+	})
+	return &event, nil
+}
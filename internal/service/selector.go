@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+// Режимы выбора ревьюверов: random - равновероятно из активных участников команды,
+// spread - пары автор -> ревьювер, которые часто встречались за последние pairWindow,
+// выбираются реже, чтобы знания о коде расходились по команде
+const (
+	AssignmentRandom = "random"
+	AssignmentSpread = "spread"
+)
+
+const defaultPairWindow = 30 * 24 * time.Hour
+
+// defaultReviewerCount - сколько ревьюверов назначается на новый PR
+const defaultReviewerCount = 2
+
+func WithReviewerCount(n int) Option {
+	return func(s *Service) {
+		s.reviewerCount = n
+	}
+}
+
+func WithAssignmentMode(mode string, pairWindow time.Duration) Option {
+	return func(s *Service) {
+		s.assignmentMode = mode
+		s.pairWindow = pairWindow
+	}
+}
+
+// pairCounts - сколько раз каждого ревьювера недавно назначали на PR автора.
+// nil в режиме random: выбор без весов
+func (s *Service) pairCounts(ctx context.Context, authorID string) (map[string]int, error) {
+	if s.assignmentMode != AssignmentSpread {
+		return nil, nil
+	}
+
+	from := time.Now().Add(-s.pairWindow)
+	pairs, err := s.repo.GetReviewPairs(ctx, models.PairFilter{
+		TimeWindow: models.TimeWindow{From: &from},
+		AuthorID:   authorID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int, len(pairs))
+	for _, pair := range pairs {
+		counts[pair.ReviewerID] = pair.Count
+	}
+	return counts, nil
+}
+
+// pickWeighted выбирает до n кандидатов без повторов; вес кандидата 1/(1+k),
+// где k - число недавних назначений на PR того же автора
+func pickWeighted(candidates []string, n int, pairs map[string]int) []string {
+	remaining := append([]string(nil), candidates...)
+	picked := make([]string, 0, n)
+
+	for len(picked) < n && len(remaining) > 0 {
+		weights := make([]float64, len(remaining))
+		var total float64
+		for i, id := range remaining {
+			weights[i] = 1 / float64(1+pairs[id])
+			total += weights[i]
+		}
+
+		i := 0
+		for x := rand.Float64() * total; i < len(remaining)-1; i++ {
+			x -= weights[i]
+			if x < 0 {
+				break
+			}
+		}
+		picked = append(picked, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return picked
+}
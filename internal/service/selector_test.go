@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+func TestPickWeightedWithoutRepeats(t *testing.T) {
+	picked := pickWeighted([]string{"a", "b", "c"}, 5, map[string]int{})
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, picked)
+}
+
+func TestPickWeightedPenalizesFrequentPairs(t *testing.T) {
+	pairs := map[string]int{"frequent": 99}
+	counts := map[string]int{}
+	for i := 0; i < 2000; i++ {
+		counts[pickWeighted([]string{"frequent", "fresh"}, 1, pairs)[0]]++
+	}
+
+	// ожидаемая доля frequent - около 1%
+	assert.Less(t, counts["frequent"], 100)
+	assert.Greater(t, counts["fresh"], 1900)
+}
+
+func TestSelectReviewersCount(t *testing.T) {
+	team := &models.Team{Members: []models.TeamMember{
+		{UserID: "author", IsActive: true},
+		{UserID: "u1", IsActive: true},
+		{UserID: "u2", IsActive: true},
+		{UserID: "u3", IsActive: true},
+		{UserID: "inactive", IsActive: false},
+	}}
+
+	s := NewService(nil)
+	picked := s.selectReviewers(team, "author", nil)
+	assert.Len(t, picked, 2)
+	assert.NotContains(t, picked, "author")
+	assert.NotContains(t, picked, "inactive")
+
+	s = NewService(nil, WithReviewerCount(5))
+	assert.ElementsMatch(t, []string{"u1", "u2", "u3"}, s.selectReviewers(team, "author", nil))
+}
@@ -3,33 +3,90 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log/slog"
 	"math/rand"
 	"time"
 
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/events"
 	"github.com/denvyworking/pr-reviewer-service/internal/models"
 	"github.com/denvyworking/pr-reviewer-service/internal/repository"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
 )
 
 var (
 	ErrTeamExists           = errors.New("team already exists")
 	ErrPRExists             = errors.New("PR already exists")
 	ErrPRMerged             = errors.New("PR is merged")
+	ErrPRClosed             = errors.New("PR is closed")
 	ErrNotAssigned          = errors.New("reviewer is not assigned")
 	ErrNoCandidate          = errors.New("no active replacement candidate")
 	ErrNotFound             = errors.New("resource not found")
 	ErrBulkDeactivateFailed = errors.New("bulk deactivate failed - some PRs cannot be reassigned")
+	ErrTeamCycle            = errors.New("team hierarchy cycle")
+	ErrUnauthorized         = errors.New("invalid credentials")
+	ErrForbidden            = errors.New("permission denied")
+	ErrInvalidRequest       = errors.New("invalid request")
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyInFlight  = errors.New("request with this idempotency key is still in progress")
+	// ErrIdempotencyLeaseLost - резервацию ключа после lease перехватил повтор запроса
+	ErrIdempotencyLeaseLost = errors.New("idempotency key reservation was taken over")
+	ErrPreconditionFailed   = errors.New("resource was modified since it was read")
+	ErrConflict             = errors.New("resource is being modified concurrently, retry the request")
 )
 
+// apiKeyTouchInterval - last_used_at обновляется не чаще, чтобы не писать в БД на каждый запрос
+const apiKeyTouchInterval = time.Minute
+
 type Service struct {
-	repo repository.Repository
+	repo           repository.Repository
+	publisher      Publisher
+	metrics        Metrics
+	idempotencyTTL time.Duration
+	// idempotencyLease - через сколько незавершённую резервацию можно перехватить
+	idempotencyLease time.Duration
+
+	fairnessThreshold float64
+	reviewerCount     int
+	assignmentMode    string
+	pairWindow        time.Duration
 }
 
-func NewService(repo repository.Repository) *Service {
+func NewService(repo repository.Repository, opts ...Option) *Service {
 	rand.Seed(time.Now().UnixNano())
-	return &Service{repo: repo}
+	s := &Service{
+		repo:              repo,
+		publisher:         nopPublisher{},
+		metrics:           nopMetrics{},
+		idempotencyTTL:    defaultIdempotencyTTL,
+		idempotencyLease:  defaultIdempotencyLease,
+		fairnessThreshold: defaultFairnessThreshold,
+		reviewerCount:     defaultReviewerCount,
+		assignmentMode:    AssignmentRandom,
+		pairWindow:        defaultPairWindow,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// inTx выполняет fn в одной транзакции: изменение и его запись в журнале аудита
+// фиксируются вместе. Внутри fn s.repo - репозиторий транзакции; события и метрики
+// отправляются вызывающим уже после fn, чтобы не сообщать об откаченных изменениях
+func (s *Service) inTx(ctx context.Context, fn func(tx *Service) error) error {
+	return s.repo.WithTx(ctx, func(repo repository.Repository) error {
+		tx := *s
+		tx.repo = repo
+		return fn(&tx)
+	})
 }
 
 func (s *Service) CreateTeam(ctx context.Context, team *models.Team) error {
+	ctx, span := tracing.Start(ctx, "Service.CreateTeam", tracing.Team(team.TeamName))
+	defer span.End()
+
 	exists, err := s.repo.TeamExists(ctx, team.TeamName)
 	if err != nil {
 		return err
@@ -38,14 +95,154 @@ func (s *Service) CreateTeam(ctx context.Context, team *models.Team) error {
 		return ErrTeamExists
 	}
 
-	return s.repo.CreateTeam(ctx, team)
+	if team.ParentTeam != "" {
+		// новая команда ещё ничей не предок, поэтому цикл возможен только на саму себя
+		if team.ParentTeam == team.TeamName {
+			return ErrTeamCycle
+		}
+		parentExists, err := s.repo.TeamExists(ctx, team.ParentTeam)
+		if err != nil {
+			return err
+		}
+		if !parentExists {
+			return ErrNotFound
+		}
+	}
+
+	// CreateTeam перезаписывает уже существующих пользователей (команду и активность),
+	// поэтому их прежнее состояние тоже попадает в журнал
+	previous := make(map[string]*models.User, len(team.Members))
+	for _, member := range team.Members {
+		user, err := s.repo.GetUser(ctx, member.UserID)
+		if err != nil {
+			return err
+		}
+		if user != nil {
+			previous[member.UserID] = user
+		}
+	}
+
+	return s.inTx(ctx, func(tx *Service) error {
+		if err := tx.repo.CreateTeam(ctx, team); err != nil {
+			return err
+		}
+
+		if err := tx.audit(ctx, AuditTeamCreate, auditTargetTeam, team.TeamName, nil, team); err != nil {
+			return err
+		}
+		for _, member := range team.Members {
+			before, ok := previous[member.UserID]
+			if !ok {
+				continue
+			}
+			after := &models.User{
+				UserID:   member.UserID,
+				Username: member.Username,
+				TeamName: team.TeamName,
+				IsActive: member.IsActive,
+			}
+			if err := tx.audit(ctx, AuditUserUpsert, auditTargetUser, member.UserID, before, after); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 func (s *Service) GetTeam(ctx context.Context, teamName string) (*models.Team, error) {
-	return s.repo.GetTeam(ctx, teamName)
+	ctx, span := tracing.Start(ctx, "Service.GetTeam", tracing.Team(teamName))
+	defer span.End()
+
+	team, err := s.repo.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if team == nil {
+		return nil, ErrNotFound
+	}
+	return team, nil
+}
+
+// SetTeamParent перевешивает команду в иерархии, пустой parentTeam делает её корнем
+func (s *Service) SetTeamParent(ctx context.Context, teamName, parentTeam string) (*models.Team, error) {
+	ctx, span := tracing.Start(ctx, "Service.SetTeamParent", tracing.Team(teamName))
+	defer span.End()
+
+	before, err := s.GetTeam(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentTeam != "" {
+		// предки будущего родителя (включая его самого) не должны содержать команду
+		ancestors, err := s.repo.GetTeamAncestors(ctx, parentTeam)
+		if err != nil {
+			return nil, err
+		}
+		if len(ancestors) == 0 {
+			return nil, ErrNotFound
+		}
+		if s.contains(ancestors, teamName) {
+			return nil, ErrTeamCycle
+		}
+	}
+
+	var after *models.Team
+	err = s.inTx(ctx, func(tx *Service) error {
+		if err := tx.repo.SetTeamParent(ctx, teamName, parentTeam); err != nil {
+			return err
+		}
+
+		after, err = tx.GetTeam(ctx, teamName)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditTeamSetParent, auditTargetTeam, teamName, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// GetTeamSubtree собирает дерево команд с корнем в teamName
+func (s *Service) GetTeamSubtree(ctx context.Context, teamName string) (*models.TeamNode, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetTeamSubtree", tracing.Team(teamName))
+	defer span.End()
+
+	nodes, err := s.repo.GetTeamSubtree(ctx, teamName)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, ErrNotFound
+	}
+
+	byName := make(map[string]*models.TeamNode, len(nodes))
+	for i := range nodes {
+		nodes[i].Children = []*models.TeamNode{}
+		byName[nodes[i].TeamName] = &nodes[i]
+	}
+
+	root := byName[teamName]
+	for i := range nodes {
+		node := &nodes[i]
+		if node == root {
+			continue
+		}
+		if parent, ok := byName[node.ParentTeam]; ok {
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	return root, nil
 }
 
 func (s *Service) SetUserActivity(ctx context.Context, userID string, isActive bool) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "Service.SetUserActivity", tracing.User(userID))
+	defer span.End()
+
 	user, err := s.repo.GetUser(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -53,11 +250,334 @@ func (s *Service) SetUserActivity(ctx context.Context, userID string, isActive b
 	if user == nil {
 		return nil, ErrNotFound
 	}
+	if err := s.authorizeUserChange(ctx, user); err != nil {
+		return nil, err
+	}
+
+	var updated *models.User
+	err = s.inTx(ctx, func(tx *Service) error {
+		updated, err = tx.repo.UpdateUserActivity(ctx, userID, isActive)
+		if err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditUserSetActivity, auditTargetUser, userID, user, updated)
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publisher.Publish(events.Event{
+		Type:     events.TypeUserActivity,
+		TeamName: updated.TeamName,
+		UserIDs:  []string{userID},
+		Data:     updated,
+	})
 
-	return s.repo.UpdateUserActivity(ctx, userID, isActive)
+	return updated, nil
 }
 
-func (s *Service) selectReviewers(team *models.Team, authorID string) []string {
+func (s *Service) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetUser", tracing.User(userID))
+	defer span.End()
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	return user, nil
+}
+
+// UpdateUser меняет имя, команду и/или активность пользователя одной транзакцией.
+// Тимлид может менять только своих и не может переводить их в чужую команду
+func (s *Service) UpdateUser(ctx context.Context, request models.UpdateUserRequest) (*models.User, error) {
+	ctx, span := tracing.Start(ctx, "Service.UpdateUser", tracing.User(request.UserID))
+	defer span.End()
+
+	if request.Username == nil && request.TeamName == nil && request.IsActive == nil {
+		return nil, fmt.Errorf("%w: nothing to update", ErrInvalidRequest)
+	}
+
+	user, err := s.repo.GetUser(ctx, request.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrNotFound
+	}
+	if err := s.authorizeUserChange(ctx, user); err != nil {
+		return nil, err
+	}
+
+	updated := *user
+	if request.Username != nil {
+		if *request.Username == "" {
+			return nil, fmt.Errorf("%w: username must not be empty", ErrInvalidRequest)
+		}
+		updated.Username = *request.Username
+	}
+	if request.TeamName != nil && *request.TeamName != user.TeamName {
+		exists, err := s.repo.TeamExists(ctx, *request.TeamName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("%w: team %q", ErrNotFound, *request.TeamName)
+		}
+		updated.TeamName = *request.TeamName
+		if err := s.authorizeUserChange(ctx, &updated); err != nil {
+			return nil, err
+		}
+	}
+
+	result := user
+	err = s.inTx(ctx, func(tx *Service) error {
+		if request.Username != nil || request.TeamName != nil {
+			if result, err = tx.repo.UpdateUser(ctx, user.UserID, updated.Username, updated.TeamName); err != nil {
+				return err
+			}
+		}
+		if request.IsActive != nil {
+			if result, err = tx.repo.UpdateUserActivity(ctx, user.UserID, *request.IsActive); err != nil {
+				return err
+			}
+		}
+		return tx.audit(ctx, AuditUserUpdate, auditTargetUser, user.UserID, user, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if request.IsActive != nil {
+		s.publisher.Publish(events.Event{
+			Type:     events.TypeUserActivity,
+			TeamName: result.TeamName,
+			UserIDs:  []string{result.UserID},
+			Data:     result,
+		})
+	}
+
+	return result, nil
+}
+
+// authorizeUserChange - тимлид может менять только пользователей своей команды
+func (s *Service) authorizeUserChange(ctx context.Context, user *models.User) error {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil || principal.Role != auth.RoleTeamLead {
+		return nil
+	}
+	if principal.TeamName == "" || principal.TeamName != user.TeamName {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Authenticate находит владельца API-ключа по секрету. Отозванные и
+// просроченные ключи не принимаются
+func (s *Service) Authenticate(ctx context.Context, secret string) (*auth.Principal, error) {
+	ctx, span := tracing.Start(ctx, "Service.Authenticate")
+	defer span.End()
+
+	key, err := s.repo.GetAPIKeyByHash(ctx, auth.HashKey(secret))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if key == nil || key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return nil, ErrUnauthorized
+	}
+
+	// учёт использования не должен ломать авторизацию: ошибка только пишется в лог
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, key.KeyID, now); err != nil {
+			slog.WarnContext(ctx, "failed to update api key usage", "key_id", key.KeyID, "error", err)
+		}
+	}
+
+	scopes := make([]auth.Permission, 0, len(key.Scopes))
+	for _, scope := range key.Scopes {
+		scopes = append(scopes, auth.Permission(scope))
+	}
+
+	return &auth.Principal{
+		KeyID:    key.KeyID,
+		Name:     key.Name,
+		Role:     auth.Role(key.Role),
+		Scopes:   scopes,
+		UserID:   key.UserID,
+		TeamName: key.TeamName,
+	}, nil
+}
+
+// CreateAPIKey выпускает ключ и возвращает его секрет - больше его узнать нельзя
+func (s *Service) CreateAPIKey(ctx context.Context, request models.CreateAPIKeyRequest) (*models.APIKey, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreateAPIKey")
+	defer span.End()
+
+	if request.Name == "" {
+		return nil, "", fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+
+	role := auth.Role(request.Role)
+	if !role.Valid() {
+		return nil, "", fmt.Errorf("%w: unknown role %q", ErrInvalidRequest, request.Role)
+	}
+	for _, scope := range request.Scopes {
+		if !role.Grants(auth.Permission(scope)) {
+			return nil, "", fmt.Errorf("%w: scope %q is not granted to role %s", ErrInvalidRequest, scope, role)
+		}
+	}
+
+	now := time.Now()
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(now) {
+		return nil, "", fmt.Errorf("%w: expires_at must be in the future", ErrInvalidRequest)
+	}
+
+	// тимлиду нужен пользователь, иначе непонятно, какой командой он управляет
+	if role == auth.RoleTeamLead && request.UserID == "" {
+		return nil, "", fmt.Errorf("%w: user_id is required for role %s", ErrInvalidRequest, role)
+	}
+	if request.UserID != "" {
+		user, err := s.repo.GetUser(ctx, request.UserID)
+		if err != nil {
+			return nil, "", err
+		}
+		if user == nil {
+			return nil, "", ErrNotFound
+		}
+	}
+
+	keyID, err := auth.GenerateKeyID()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := auth.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	scopes := request.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	key := &models.APIKey{
+		KeyID:     keyID,
+		Name:      request.Name,
+		Role:      string(role),
+		Scopes:    scopes,
+		UserID:    request.UserID,
+		CreatedAt: &now,
+		ExpiresAt: request.ExpiresAt,
+	}
+	err = s.inTx(ctx, func(tx *Service) error {
+		if err := tx.repo.CreateAPIKey(ctx, key, auth.HashKey(secret)); err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditAPIKeyCreate, auditTargetAPIKey, keyID, nil, key)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (s *Service) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "Service.ListAPIKeys")
+	defer span.End()
+
+	return s.repo.ListAPIKeys(ctx)
+}
+
+// RevokeAPIKey идемпотентен: повторный отзыв возвращает уже отозванный ключ
+func (s *Service) RevokeAPIKey(ctx context.Context, keyID string) (*models.APIKey, error) {
+	ctx, span := tracing.Start(ctx, "Service.RevokeAPIKey", tracing.APIKey(keyID))
+	defer span.End()
+
+	key, err := s.repo.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrNotFound
+	}
+	if key.RevokedAt != nil {
+		return key, nil
+	}
+
+	before := *key
+	now := time.Now()
+	key.RevokedAt = &now
+	err = s.inTx(ctx, func(tx *Service) error {
+		if err := tx.repo.RevokeAPIKey(ctx, keyID, now); err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditAPIKeyRevoke, auditTargetAPIKey, keyID, before, key)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// AuthenticateUser строит principal для пользователя, подтверждённого
+// внешним провайдером (sub из JWT = users.user_id)
+func (s *Service) AuthenticateUser(ctx context.Context, userID string) (*auth.Principal, error) {
+	ctx, span := tracing.Start(ctx, "Service.AuthenticateUser", tracing.User(userID))
+	defer span.End()
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUnauthorized
+	}
+
+	return &auth.Principal{
+		Name:     user.Username,
+		Role:     auth.RoleMember,
+		UserID:   user.UserID,
+		TeamName: user.TeamName,
+	}, nil
+}
+
+// EnsureAdminKey заводит admin-ключ с заданным секретом, если его ещё нет
+func (s *Service) EnsureAdminKey(ctx context.Context, secret string) error {
+	ctx, span := tracing.Start(ctx, "Service.EnsureAdminKey")
+	defer span.End()
+
+	existing, err := s.repo.GetAPIKeyByHash(ctx, auth.HashKey(secret))
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+
+	keyID, err := auth.GenerateKeyID()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	key := &models.APIKey{
+		KeyID:     keyID,
+		Name:      "bootstrap-admin",
+		Role:      string(auth.RoleAdmin),
+		Scopes:    []string{},
+		CreatedAt: &now,
+	}
+	return s.inTx(ctx, func(tx *Service) error {
+		if err := tx.repo.CreateAPIKey(ctx, key, auth.HashKey(secret)); err != nil {
+			return err
+		}
+		return tx.audit(ctx, AuditAPIKeyCreate, auditTargetAPIKey, keyID, nil, key)
+	})
+}
+
+// pairs - недавние назначения на PR автора для режима spread, nil - равновероятный выбор
+func (s *Service) selectReviewers(team *models.Team, authorID string, pairs map[string]int) []string {
 	var candidates []string
 
 	for _, member := range team.Members {
@@ -66,22 +586,17 @@ func (s *Service) selectReviewers(team *models.Team, authorID string) []string {
 		}
 	}
 
-	if len(candidates) <= 1 {
+	if len(candidates) <= s.reviewerCount {
 		return candidates
 	}
-
-	//Первый случайный индекс
-	idx1 := rand.Intn(len(candidates))
-
-	idx2 := rand.Intn(len(candidates) - 1)
-	if idx2 == idx1 {
-		idx2++
-	}
-
-	return []string{candidates[idx1], candidates[idx2]}
+	// без истории пар (режим random) веса равны и выбор равновероятный
+	return pickWeighted(candidates, s.reviewerCount, pairs)
 }
 
 func (s *Service) CreatePR(ctx context.Context, prID, prName, authorID string) (*models.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "Service.CreatePR", tracing.PRID(prID), tracing.User(authorID))
+	defer span.End()
+
 	exists, err := s.repo.PRExists(ctx, prID)
 	if err != nil {
 		return nil, err
@@ -106,7 +621,11 @@ func (s *Service) CreatePR(ctx context.Context, prID, prName, authorID string) (
 		return nil, ErrNotFound
 	}
 
-	reviewers := s.selectReviewers(team, authorID)
+	pairs, err := s.pairCounts(ctx, authorID)
+	if err != nil {
+		return nil, err
+	}
+	reviewers := s.selectReviewers(team, authorID, pairs)
 
 	now := time.Now()
 	pr := &models.PullRequest{
@@ -118,15 +637,82 @@ func (s *Service) CreatePR(ctx context.Context, prID, prName, authorID string) (
 		CreatedAt:         &now,
 	}
 
-	err = s.repo.CreatePR(ctx, pr)
+	prEvents := []models.PREvent{{PullRequestID: prID, EventType: models.PREventCreated, CreatedAt: now}}
+	for _, reviewer := range reviewers {
+		prEvents = append(prEvents, models.PREvent{
+			PullRequestID: prID,
+			EventType:     models.PREventReviewerAssigned,
+			UserID:        reviewer,
+			Cause:         models.CauseAutoAssign,
+			CreatedAt:     now,
+		})
+	}
+
+	err = s.inTx(ctx, func(tx *Service) error {
+		if err := tx.repo.CreatePR(ctx, pr); err != nil {
+			return err
+		}
+		if err := tx.audit(ctx, AuditPRCreate, auditTargetPR, prID, nil, pr); err != nil {
+			return err
+		}
+		return tx.recordPREvents(ctx, prEvents...)
+	})
 	if err != nil {
 		return nil, err
 	}
 
+	s.metrics.PRChanged(PRActionCreated)
+	s.publisher.Publish(events.Event{
+		Type:          events.TypePRCreated,
+		TeamName:      team.TeamName,
+		PullRequestID: prID,
+		UserIDs:       append([]string{authorID}, reviewers...),
+		Data:          pr,
+	})
+	for _, reviewer := range reviewers {
+		s.publisher.Publish(events.Event{
+			Type:          events.TypeReviewerAssigned,
+			TeamName:      team.TeamName,
+			PullRequestID: prID,
+			UserIDs:       []string{reviewer},
+			Data:          map[string]string{"user_id": reviewer},
+		})
+	}
+
+	return pr, nil
+}
+
+func (s *Service) GetPR(ctx context.Context, prID string) (*models.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetPR", tracing.PRID(prID))
+	defer span.End()
+
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, ErrNotFound
+	}
 	return pr, nil
 }
 
 func (s *Service) MergePR(ctx context.Context, prID string) (*models.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "Service.MergePR", tracing.PRID(prID))
+	defer span.End()
+
+	var pr *models.PullRequest
+	err := retryOnConflict(ctx, func() (err error) {
+		pr, err = s.mergePR(ctx, prID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+// mergePR - одна попытка мержа по прочитанной версии PR
+func (s *Service) mergePR(ctx context.Context, prID string) (*models.PullRequest, error) {
 	PullRequest, err := s.repo.GetPR(ctx, prID)
 	if err != nil {
 		return nil, err
@@ -134,20 +720,141 @@ func (s *Service) MergePR(ctx context.Context, prID string) (*models.PullRequest
 	if PullRequest == nil {
 		return nil, ErrNotFound
 	}
+	if err := checkVersion(ctx, PullRequest.Version); err != nil {
+		return nil, err
+	}
 	// индемпотентность
 	if PullRequest.Status == models.StatusMerged {
 		return PullRequest, nil
 	}
+	if PullRequest.Status == models.StatusClosed {
+		return nil, ErrPRClosed
+	}
+	before := *PullRequest
 	time := time.Now()
-	if err := s.repo.UpdatePRStatus(ctx, prID, models.StatusMerged, &time); err != nil {
+	err = s.inTx(ctx, func(tx *Service) error {
+		version, err := tx.repo.UpdatePRStatus(ctx, prID, models.StatusMerged, &time, PullRequest.Version)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return errVersionConflict
+		}
+		PullRequest.Status = models.StatusMerged
+		PullRequest.MergedAt = &time
+		PullRequest.Version = version
+		if err := tx.audit(ctx, AuditPRMerge, auditTargetPR, prID, before, PullRequest); err != nil {
+			return err
+		}
+		return tx.recordPREvents(ctx, models.PREvent{PullRequestID: prID, EventType: models.PREventMerged, CreatedAt: time})
+	})
+	if err != nil {
 		return nil, err
 	}
-	PullRequest.Status = models.StatusMerged
-	PullRequest.MergedAt = &time
+	s.metrics.PRChanged(PRActionMerged)
+	s.publisher.Publish(events.Event{
+		Type:          events.TypePRMerged,
+		TeamName:      s.userTeam(ctx, PullRequest.AuthorID),
+		PullRequestID: prID,
+		UserIDs:       append([]string{PullRequest.AuthorID}, PullRequest.AssignedReviewers...),
+		Data:          PullRequest,
+	})
 	return PullRequest, nil
 }
 
-func (s *Service) ReassignReviewer(ctx context.Context, prID, oldUserID string) (*models.PullRequest, string, error) {
+// ClosePR закрывает PR без мержа; повторное закрытие возвращает PR без изменений
+func (s *Service) ClosePR(ctx context.Context, prID string) (*models.PullRequest, error) {
+	ctx, span := tracing.Start(ctx, "Service.ClosePR", tracing.PRID(prID))
+	defer span.End()
+
+	var pr *models.PullRequest
+	err := retryOnConflict(ctx, func() (err error) {
+		pr, err = s.closePR(ctx, prID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pr, nil
+}
+
+func (s *Service) closePR(ctx context.Context, prID string) (*models.PullRequest, error) {
+	pr, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return nil, err
+	}
+	if pr == nil {
+		return nil, ErrNotFound
+	}
+	if err := checkVersion(ctx, pr.Version); err != nil {
+		return nil, err
+	}
+	switch pr.Status {
+	case models.StatusClosed:
+		return pr, nil
+	case models.StatusMerged:
+		return nil, ErrPRMerged
+	}
+
+	before := *pr
+	now := time.Now()
+	err = s.inTx(ctx, func(tx *Service) error {
+		version, err := tx.repo.UpdatePRStatus(ctx, prID, models.StatusClosed, nil, pr.Version)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return errVersionConflict
+		}
+		pr.Status = models.StatusClosed
+		pr.Version = version
+		if err := tx.audit(ctx, AuditPRClose, auditTargetPR, prID, before, pr); err != nil {
+			return err
+		}
+		return tx.recordPREvents(ctx, models.PREvent{PullRequestID: prID, EventType: models.PREventClosed, CreatedAt: now})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.metrics.PRChanged(PRActionClosed)
+	s.publisher.Publish(events.Event{
+		Type:          events.TypePRClosed,
+		TeamName:      s.userTeam(ctx, pr.AuthorID),
+		PullRequestID: prID,
+		UserIDs:       append([]string{pr.AuthorID}, pr.AssignedReviewers...),
+		Data:          pr,
+	})
+	return pr, nil
+}
+
+// ReassignReviewer заменяет ревьювера. cause - manual_reassign (по умолчанию) или
+// escalation, если ревьювер не отвечает по PR
+func (s *Service) ReassignReviewer(ctx context.Context, prID, oldUserID string, cause models.AssignmentCause) (*models.PullRequest, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.ReassignReviewer", tracing.PRID(prID), tracing.User(oldUserID))
+	defer span.End()
+
+	switch cause {
+	case "":
+		cause = models.CauseManualReassign
+	case models.CauseManualReassign, models.CauseEscalation:
+	default:
+		return nil, "", fmt.Errorf("%w: cause must be manual_reassign or escalation", ErrInvalidRequest)
+	}
+
+	var pr *models.PullRequest
+	var newReviewerID string
+	err := retryOnConflict(ctx, func() (err error) {
+		pr, newReviewerID, err = s.reassignReviewer(ctx, prID, oldUserID, cause)
+		return err
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return pr, newReviewerID, nil
+}
+
+func (s *Service) reassignReviewer(ctx context.Context, prID, oldUserID string, cause models.AssignmentCause) (*models.PullRequest, string, error) {
 	pr, err := s.repo.GetPR(ctx, prID)
 	if err != nil {
 		return nil, "", err
@@ -155,11 +862,17 @@ func (s *Service) ReassignReviewer(ctx context.Context, prID, oldUserID string)
 	if pr == nil {
 		return nil, "", ErrNotFound
 	}
+	if err := checkVersion(ctx, pr.Version); err != nil {
+		return nil, "", err
+	}
 
 	// Проверка что PR не мержен!!!
 	if pr.Status == models.StatusMerged {
 		return nil, "", ErrPRMerged
 	}
+	if pr.Status == models.StatusClosed {
+		return nil, "", ErrPRClosed
+	}
 
 	if !s.contains(pr.AssignedReviewers, oldUserID) {
 		return nil, "", ErrNotAssigned
@@ -181,27 +894,146 @@ func (s *Service) ReassignReviewer(ctx context.Context, prID, oldUserID string)
 		return nil, "", ErrNotFound
 	}
 
-	newReviewerID := s.selectReplacementReviewer(team, oldUserID, pr.AuthorID, pr.AssignedReviewers)
+	pairs, err := s.pairCounts(ctx, pr.AuthorID)
+	if err != nil {
+		return nil, "", err
+	}
+	newReviewerID := s.selectReplacementReviewer(team, oldUserID, pr.AuthorID, pr.AssignedReviewers, pairs)
 	if newReviewerID == "" {
+		s.metrics.NoCandidate(cause)
 		return nil, "", ErrNoCandidate
 	}
 
 	newReviewers := s.replaceReviewer(pr.AssignedReviewers, oldUserID, newReviewerID) // используем метод структуры models.PullRequest
 
-	err = s.repo.UpdatePRReviewers(ctx, prID, newReviewers)
+	before := *pr
+	err = s.inTx(ctx, func(tx *Service) error {
+		version, err := tx.repo.UpdatePRReviewers(ctx, prID, newReviewers, pr.Version)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return errVersionConflict
+		}
+
+		pr.AssignedReviewers = newReviewers
+		pr.Version = version
+		if err := tx.audit(ctx, AuditPRReassign, auditTargetPR, prID, before, pr); err != nil {
+			return err
+		}
+		return tx.recordPREvents(ctx, reassignmentEvents(prID, oldUserID, newReviewerID, cause)...)
+	})
 	if err != nil {
 		return nil, "", err
 	}
-
-	pr.AssignedReviewers = newReviewers
+	s.metrics.PRChanged(PRActionReassigned)
+	s.publisher.Publish(reassignedEvent(team.TeamName, prID, oldUserID, newReviewerID, cause))
 	return pr, newReviewerID, nil
 }
 
-func (s *Service) GetReviewStats(ctx context.Context) ([]models.ReviewStat, error) {
-	return s.repo.GetReviewStats(ctx)
+// GetReviewStats возвращает страницу статистики по ревьюверам за период и курсор следующей.
+// С фильтром по команде дополнительно возвращает агрегаты по каждому узлу
+// (команда или всё её поддерево) - они не пагинируются
+func (s *Service) GetReviewStats(ctx context.Context, filter models.ReviewStatsFilter) ([]models.ReviewStat, []models.TeamReviewStat, string, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetReviewStats", tracing.Team(filter.TeamName))
+	defer span.End()
+
+	if err := validateWindow(filter.TimeWindow); err != nil {
+		return nil, nil, "", err
+	}
+	if filter.TeamName == "" {
+		stats, next, err := s.reviewStatsPage(ctx, nil, filter)
+		return stats, nil, next, err
+	}
+
+	teamNames, parents, err := s.statsTeams(ctx, filter.TeamName, filter.Subtree)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	teamStats, err := s.repo.GetTeamReviewCounts(ctx, teamNames, filter.TimeWindow)
+	if err != nil {
+		return nil, nil, "", err
+	}
+	if len(teamStats) == 0 {
+		return nil, nil, "", ErrNotFound
+	}
+
+	stats, next, err := s.reviewStatsPage(ctx, teamNames, filter)
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	// сворачиваем счётчики снизу вверх: сначала каждый узел считает себя,
+	// затем прибавляется ко всем предкам внутри выбранного поддерева
+	totals := make(map[string]int, len(teamStats))
+	for _, stat := range teamStats {
+		for name := stat.TeamName; name != ""; name = parents[name] {
+			totals[name] += stat.ReviewCount
+			if name == filter.TeamName {
+				break
+			}
+		}
+	}
+	for i := range teamStats {
+		teamStats[i].TotalReviewCount = totals[teamStats[i].TeamName]
+	}
+
+	return stats, teamStats, next, nil
+}
+
+// statsTeams - команды, по которым считается статистика: сама команда или всё её поддерево
+// вместе с родителем каждого узла
+func (s *Service) statsTeams(ctx context.Context, teamName string, subtree bool) ([]string, map[string]string, error) {
+	parents := map[string]string{}
+	if !subtree {
+		return []string{teamName}, parents, nil
+	}
+
+	nodes, err := s.repo.GetTeamSubtree(ctx, teamName)
+	if err != nil {
+		return nil, nil, err
+	}
+	teamNames := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		teamNames = append(teamNames, node.TeamName)
+		parents[node.TeamName] = node.ParentTeam
+	}
+	return teamNames, parents, nil
+}
+
+func (s *Service) reviewStatsPage(ctx context.Context, teamNames []string, filter models.ReviewStatsFilter) ([]models.ReviewStat, string, error) {
+	var after *models.StatCursor
+	if filter.Cursor != "" {
+		after = &models.StatCursor{}
+		if err := decodeCursor(filter.Cursor, after); err != nil {
+			return nil, "", err
+		}
+	}
+	if filter.Limit == Unpaged {
+		stats, err := s.repo.GetReviewStats(ctx, teamNames, filter.TimeWindow, after, 0)
+		return stats, "", err
+	}
+
+	limit := pageLimit(filter.Limit)
+	stats, err := s.repo.GetReviewStats(ctx, teamNames, filter.TimeWindow, after, limit+1)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if len(stats) > limit {
+		stats = stats[:limit]
+		last := stats[limit-1]
+		next = encodeCursor(models.StatCursor{ReviewCount: last.ReviewCount, UserID: last.UserID})
+	}
+	return stats, next, nil
 }
 
 func (s *Service) BulkDeactivateUsers(ctx context.Context, userIDs []string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "Service.BulkDeactivateUsers", tracing.Users(userIDs))
+	defer span.End()
+
 	deactivated := make([]string, 0, len(userIDs))
 
 	// Фаза 1: Проверка возможности переназначения всех PR
@@ -213,15 +1045,28 @@ func (s *Service) BulkDeactivateUsers(ctx context.Context, userIDs []string) ([]
 		if user == nil {
 			return nil, ErrNotFound
 		}
+		if err := s.authorizeUserChange(ctx, user); err != nil {
+			return nil, err
+		}
 
-		prs, err := s.repo.GetPRsByReviewer(ctx, userID)
+		prs, err := s.repo.GetPRsByReviewer(ctx, userID, models.ReviewFilter{Status: models.StatusOpen})
 		if err != nil {
 			return nil, err
 		}
 
 		for _, pr := range prs {
 			if pr.Status == models.StatusOpen {
-				newReviewer := s.selectReplacementReviewerForBulk(pr.PullRequestID, userID, user.TeamName)
+				fullPR, err := s.repo.GetPR(ctx, pr.PullRequestID)
+				if err != nil {
+					return nil, err
+				}
+				if fullPR == nil {
+					continue
+				}
+				newReviewer, err := s.selectReplacementReviewerForBulk(ctx, fullPR, userID, user.TeamName)
+				if err != nil {
+					return nil, err
+				}
 				if newReviewer == "" {
 					return nil, ErrBulkDeactivateFailed
 				}
@@ -236,35 +1081,38 @@ func (s *Service) BulkDeactivateUsers(ctx context.Context, userIDs []string) ([]
 			return deactivated, err
 		}
 
-		prs, err := s.repo.GetPRsByReviewer(ctx, userID)
+		prs, err := s.repo.GetPRsByReviewer(ctx, userID, models.ReviewFilter{Status: models.StatusOpen})
 		if err != nil {
 			return deactivated, err
 		}
 
 		for _, pr := range prs {
 			if pr.Status == models.StatusOpen {
-				fullPR, err := s.repo.GetPR(ctx, pr.PullRequestID)
+				err := retryOnConflict(ctx, func() error {
+					return s.bulkReassign(ctx, pr.PullRequestID, user)
+				})
 				if err != nil {
 					return deactivated, err
 				}
-				if fullPR == nil {
-					continue
-				}
-
-				newReviewer := s.selectReplacementReviewerForBulk(pr.PullRequestID, userID, user.TeamName)
-				if newReviewer != "" {
-					newReviewers := s.replaceReviewer(fullPR.AssignedReviewers, userID, newReviewer)
-					err = s.repo.UpdatePRReviewers(ctx, pr.PullRequestID, newReviewers)
-					if err != nil {
-						return deactivated, err
-					}
-				}
 			}
 		}
-		_, err = s.repo.UpdateUserActivity(ctx, userID, false)
+		var updated *models.User
+		err = s.inTx(ctx, func(tx *Service) error {
+			updated, err = tx.repo.UpdateUserActivity(ctx, userID, false)
+			if err != nil {
+				return err
+			}
+			return tx.audit(ctx, AuditUserBulkDeactivate, auditTargetUser, userID, user, updated)
+		})
 		if err != nil {
 			return deactivated, err
 		}
+		s.publisher.Publish(events.Event{
+			Type:     events.TypeUserActivity,
+			TeamName: updated.TeamName,
+			UserIDs:  []string{userID},
+			Data:     updated,
+		})
 
 		deactivated = append(deactivated, userID)
 	}
@@ -272,18 +1120,70 @@ func (s *Service) BulkDeactivateUsers(ctx context.Context, userIDs []string) ([]
 	return deactivated, nil
 }
 
-func (s *Service) selectReplacementReviewerForBulk(prID, oldUserID, teamName string) string {
-	pr, err := s.repo.GetPR(context.Background(), prID)
-	if err != nil || pr == nil {
-		return ""
+// bulkReassign - одна попытка заменить деактивируемого пользователя в PR по прочитанной
+// версии. PR, который уже не открыт или где пользователя успели снять, пропускается
+func (s *Service) bulkReassign(ctx context.Context, prID string, user *models.User) error {
+	fullPR, err := s.repo.GetPR(ctx, prID)
+	if err != nil {
+		return err
+	}
+	if fullPR == nil || fullPR.Status != models.StatusOpen || !s.contains(fullPR.AssignedReviewers, user.UserID) {
+		return nil
 	}
 
-	team, err := s.repo.GetTeam(context.Background(), teamName)
-	if err != nil || team == nil {
-		return ""
+	newReviewer, err := s.selectReplacementReviewerForBulk(ctx, fullPR, user.UserID, user.TeamName)
+	if err != nil {
+		return err
+	}
+	if newReviewer == "" {
+		s.metrics.NoCandidate(models.CauseBulkDeactivation)
+		return nil
+	}
+
+	newReviewers := s.replaceReviewer(fullPR.AssignedReviewers, user.UserID, newReviewer)
+	err = s.inTx(ctx, func(tx *Service) error {
+		version, err := tx.repo.UpdatePRReviewers(ctx, prID, newReviewers, fullPR.Version)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			return errVersionConflict
+		}
+
+		after := *fullPR
+		after.AssignedReviewers = newReviewers
+		after.Version = version
+		if err := tx.audit(ctx, AuditPRBulkReassign, auditTargetPR, prID, fullPR, after); err != nil {
+			return err
+		}
+		prEvents := reassignmentEvents(prID, user.UserID, newReviewer, models.CauseBulkDeactivation)
+		return tx.recordPREvents(ctx, prEvents...)
+	})
+	if err != nil {
+		return err
+	}
+	s.metrics.PRChanged(PRActionReassigned)
+	s.publisher.Publish(reassignedEvent(user.TeamName, prID, user.UserID, newReviewer, models.CauseBulkDeactivation))
+	return nil
+}
+
+// selectReplacementReviewerForBulk - замена деактивируемому ревьюверу из его команды;
+// "" без ошибки - подходящего кандидата нет
+func (s *Service) selectReplacementReviewerForBulk(ctx context.Context, pr *models.PullRequest, oldUserID, teamName string) (string, error) {
+	team, err := s.repo.GetTeam(ctx, teamName)
+	if err != nil {
+		return "", err
+	}
+	if team == nil {
+		return "", nil
 	}
 
-	return s.selectReplacementReviewer(team, oldUserID, pr.AuthorID, pr.AssignedReviewers)
+	pairs, err := s.pairCounts(ctx, pr.AuthorID)
+	if err != nil {
+		return "", err
+	}
+
+	return s.selectReplacementReviewer(team, oldUserID, pr.AuthorID, pr.AssignedReviewers, pairs), nil
 }
 
 func (s *Service) contains(slice []string, item string) bool {
@@ -308,7 +1208,7 @@ func (s *Service) replaceReviewer(reviewers []string, oldID, newID string) []str
 }
 
 // выбрать замену для ревьювера
-func (s *Service) selectReplacementReviewer(team *models.Team, oldUserID, authorID string, currentReviewers []string) string {
+func (s *Service) selectReplacementReviewer(team *models.Team, oldUserID, authorID string, currentReviewers []string, pairs map[string]int) string {
 	var candidates []string
 
 	for _, member := range team.Members {
@@ -325,11 +1225,9 @@ func (s *Service) selectReplacementReviewer(team *models.Team, oldUserID, author
 	if len(candidates) == 0 {
 		return ""
 	}
+	if pairs != nil {
+		return pickWeighted(candidates, 1, pairs)[0]
+	}
 
 	return candidates[rand.Intn(len(candidates))]
 }
-
-func (s *Service) GetReview(ctx context.Context, UserId string) []models.PullRequestShort {
-	PRs, _ := s.repo.GetPRsByReviewer(ctx, UserId)
-	return PRs
-}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/repository"
+)
+
+// fakeRepo переопределяет только нужные тестам методы; вызов остальных
+// паникует на nil-интерфейсе, так что лишние обращения к базе видны сразу
+type fakeRepo struct {
+	repository.Repository
+
+	apiKey   *models.APIKey
+	touchErr error
+	touches  int
+
+	auditErr   error
+	audit      []models.AuditEntry
+	rolledBack bool
+
+	pr       *models.PullRequest
+	prEvents []models.PREvent
+	// conflicts - сколько следующих UPDATE не найдут прочитанную версию PR;
+	// onConflict имитирует чужое изменение, из-за которого версия разошлась
+	conflicts  int
+	onConflict func(pr *models.PullRequest)
+
+	reviews     []models.PullRequestShort
+	reviewLimit int
+
+	turnaround []models.TurnaroundRow
+
+	user        *models.User
+	activityErr error
+}
+
+// WithTx имитирует откат: записи аудита, сделанные в откаченной транзакции, отбрасываются
+func (r *fakeRepo) WithTx(ctx context.Context, fn func(tx repository.Repository) error) error {
+	saved := len(r.audit)
+	if err := fn(r); err != nil {
+		r.audit = r.audit[:saved]
+		r.rolledBack = true
+		return err
+	}
+	return nil
+}
+
+func (r *fakeRepo) InsertAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	if r.auditErr != nil {
+		return r.auditErr
+	}
+	r.audit = append(r.audit, *entry)
+	return nil
+}
+
+func (r *fakeRepo) TeamExists(ctx context.Context, teamName string) (bool, error) {
+	return false, nil
+}
+
+func (r *fakeRepo) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	if r.user == nil {
+		return nil, nil
+	}
+	user := *r.user
+	return &user, nil
+}
+
+func (r *fakeRepo) UpdateUser(ctx context.Context, userID, username, teamName string) (*models.User, error) {
+	r.user.Username, r.user.TeamName = username, teamName
+	return r.GetUser(ctx, userID)
+}
+
+func (r *fakeRepo) UpdateUserActivity(ctx context.Context, userID string, isActive bool) (*models.User, error) {
+	if r.activityErr != nil {
+		return nil, r.activityErr
+	}
+	r.user.IsActive = isActive
+	return r.GetUser(ctx, userID)
+}
+
+func (r *fakeRepo) CreateTeam(ctx context.Context, team *models.Team) error {
+	return nil
+}
+
+func (r *fakeRepo) GetPR(ctx context.Context, prID string) (*models.PullRequest, error) {
+	if r.pr == nil {
+		return nil, nil
+	}
+	pr := *r.pr
+	return &pr, nil
+}
+
+func (r *fakeRepo) UpdatePRStatus(ctx context.Context, prID string, status models.PullRequestStatus, mergedAt *time.Time, expectedVersion int64) (int64, error) {
+	if r.conflicts > 0 {
+		r.conflicts--
+		if r.onConflict != nil {
+			r.onConflict(r.pr)
+		}
+		return 0, nil
+	}
+	r.pr.Status = status
+	r.pr.Version++
+	return r.pr.Version, nil
+}
+
+func (r *fakeRepo) AddPREvents(ctx context.Context, events []models.PREvent) error {
+	r.prEvents = append(r.prEvents, events...)
+	return nil
+}
+
+func (r *fakeRepo) GetPRsByReviewer(ctx context.Context, userID string, filter models.ReviewFilter) ([]models.PullRequestShort, error) {
+	r.reviewLimit = filter.Limit
+	if filter.Limit > 0 && filter.Limit < len(r.reviews) {
+		return r.reviews[:filter.Limit], nil
+	}
+	return r.reviews, nil
+}
+
+func (r *fakeRepo) GetTurnaround(ctx context.Context, filter models.TurnaroundFilter) ([]models.TurnaroundRow, error) {
+	return r.turnaround, nil
+}
+
+func (r *fakeRepo) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	return r.apiKey, nil
+}
+
+func (r *fakeRepo) TouchAPIKey(ctx context.Context, keyID string, usedAt time.Time) error {
+	r.touches++
+	return r.touchErr
+}
+
+func TestAuthenticateUsageTracking(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{
+		apiKey:   &models.APIKey{KeyID: "k1", Role: string(auth.RoleAdmin)},
+		touchErr: errors.New("database is read-only"),
+	}
+	s := NewService(repo)
+
+	principal, err := s.Authenticate(ctx, "secret")
+	require.NoError(t, err, "usage tracking failure must not reject the key")
+	assert.Equal(t, "k1", principal.KeyID)
+	assert.Equal(t, 1, repo.touches)
+
+	recent := time.Now().Add(-10 * time.Second)
+	repo.apiKey.LastUsedAt = &recent
+	_, err = s.Authenticate(ctx, "secret")
+	require.NoError(t, err)
+	assert.Equal(t, 1, repo.touches, "recently used key is not touched again")
+}
+
+func TestMutationRolledBackWhenAuditFails(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{auditErr: errors.New("audit_log is unavailable")}
+	s := NewService(repo)
+
+	team := &models.Team{TeamName: "backend", Members: []models.TeamMember{{UserID: "u1", Username: "Alice", IsActive: true}}}
+	err := s.CreateTeam(ctx, team)
+	assert.ErrorIs(t, err, repo.auditErr)
+	assert.True(t, repo.rolledBack)
+
+	repo.auditErr, repo.rolledBack = nil, false
+	require.NoError(t, s.CreateTeam(ctx, team))
+	assert.False(t, repo.rolledBack)
+	require.Len(t, repo.audit, 1)
+	assert.Equal(t, AuditTeamCreate, repo.audit[0].Action)
+}
+
+func TestUpdateUserAppliesAllFieldsInOneTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{
+		user:        &models.User{UserID: "u1", Username: "Alice", TeamName: "backend", IsActive: true},
+		activityErr: errors.New("connection reset"),
+	}
+	s := NewService(repo)
+
+	username, inactive := "Alicia", false
+	request := models.UpdateUserRequest{UserID: "u1", Username: &username, IsActive: &inactive}
+	_, err := s.UpdateUser(ctx, request)
+	assert.ErrorIs(t, err, repo.activityErr)
+	assert.True(t, repo.rolledBack, "rename is rolled back together with the failed deactivation")
+	assert.Empty(t, repo.audit)
+
+	repo.activityErr = nil
+	user, err := s.UpdateUser(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, "Alicia", user.Username)
+	assert.False(t, user.IsActive)
+	require.Len(t, repo.audit, 1)
+	assert.Equal(t, AuditUserUpdate, repo.audit[0].Action)
+}
+
+func TestSubmitReview(t *testing.T) {
+	repo := &fakeRepo{pr: &models.PullRequest{
+		PullRequestID:     "pr-1",
+		AuthorID:          "u1",
+		Status:            models.StatusOpen,
+		AssignedReviewers: []string{"u2"},
+	}}
+	s := NewService(repo)
+	member := auth.WithPrincipal(context.Background(), &auth.Principal{Role: auth.RoleMember, UserID: "u3"})
+
+	_, err := s.SubmitReview(member, "pr-1", "u2", models.VerdictApproved)
+	assert.ErrorIs(t, err, ErrForbidden, "member submits only for themselves")
+
+	_, err = s.SubmitReview(member, "pr-1", "", models.VerdictApproved)
+	assert.ErrorIs(t, err, ErrNotAssigned)
+
+	_, err = s.SubmitReview(member, "pr-1", "", "lgtm")
+	assert.ErrorIs(t, err, ErrInvalidRequest)
+
+	reviewer := auth.WithPrincipal(context.Background(), &auth.Principal{Role: auth.RoleMember, UserID: "u2"})
+	event, err := s.SubmitReview(reviewer, "pr-1", "", models.VerdictChangesRequested)
+	require.NoError(t, err)
+	assert.Equal(t, models.PREventReviewSubmitted, event.EventType)
+	assert.Equal(t, models.VerdictChangesRequested, event.Verdict)
+	require.Len(t, repo.prEvents, 1)
+	require.Len(t, repo.audit, 1)
+	assert.Equal(t, AuditPRReview, repo.audit[0].Action)
+
+	repo.pr.Status = models.StatusClosed
+	_, err = s.SubmitReview(reviewer, "pr-1", "", models.VerdictApproved)
+	assert.ErrorIs(t, err, ErrPRClosed)
+}
+
+func TestListReviewsUnpaged(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeRepo{reviews: make([]models.PullRequestShort, defaultPageLimit+10)}
+	s := NewService(repo)
+
+	prs, next, err := s.ListReviews(ctx, "u1", models.ReviewFilter{}, "")
+	require.NoError(t, err)
+	assert.Len(t, prs, defaultPageLimit)
+	assert.NotEmpty(t, next)
+	assert.Equal(t, defaultPageLimit+1, repo.reviewLimit)
+
+	prs, next, err = s.ListReviews(ctx, "u1", models.ReviewFilter{Limit: Unpaged}, "")
+	require.NoError(t, err)
+	assert.Len(t, prs, defaultPageLimit+10, "v1 без limit получает весь список")
+	assert.Empty(t, next)
+	assert.Zero(t, repo.reviewLimit)
+}
+
+func TestMergePRVersionConflict(t *testing.T) {
+	ctx := context.Background()
+	newRepo := func() *fakeRepo {
+		return &fakeRepo{pr: &models.PullRequest{PullRequestID: "pr-1", AuthorID: "u1", Status: models.StatusOpen, Version: 1}}
+	}
+
+	// параллельный мерж успел раньше: повтор видит смерженный PR и не пишет второе событие
+	repo := newRepo()
+	repo.conflicts = 1
+	repo.onConflict = func(pr *models.PullRequest) {
+		pr.Status = models.StatusMerged
+		pr.Version = 2
+	}
+	pr, err := NewService(repo).MergePR(ctx, "pr-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusMerged, pr.Status)
+	assert.Empty(t, repo.audit)
+	assert.Empty(t, repo.prEvents)
+
+	// без If-Match операция повторяется на свежей версии
+	repo = newRepo()
+	repo.conflicts = 1
+	pr, err = NewService(repo).MergePR(ctx, "pr-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), pr.Version)
+	assert.Len(t, repo.prEvents, 1)
+
+	repo = newRepo()
+	repo.conflicts = 1
+	_, err = NewService(repo).MergePR(WithExpectedVersion(ctx, 1), "pr-1")
+	assert.ErrorIs(t, err, ErrPreconditionFailed, "с If-Match изменение чужой версии не повторяется")
+
+	repo = newRepo()
+	repo.conflicts = maxConflictRetries
+	_, err = NewService(repo).MergePR(ctx, "pr-1")
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.Empty(t, repo.prEvents)
+}
+
+func TestGetTurnaroundMetrics(t *testing.T) {
+	week := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	repo := &fakeRepo{turnaround: []models.TurnaroundRow{
+		{GroupKey: "u2", Metric: models.TurnaroundApproval, DurationStats: models.DurationStats{Count: 2, MedianSeconds: 7200}},
+		{GroupKey: "u2", Metric: models.TurnaroundFirstReview, DurationStats: models.DurationStats{Count: 3, MedianSeconds: 3600}},
+		{GroupKey: "u2", Metric: models.TurnaroundFirstReview, BucketStart: &week, DurationStats: models.DurationStats{Count: 3}},
+		{GroupKey: "u3", Metric: models.TurnaroundMerge, DurationStats: models.DurationStats{Count: 1}},
+	}}
+
+	groups, err := NewService(repo).GetTurnaround(context.Background(), models.TurnaroundFilter{GroupBy: models.TurnaroundByReviewer})
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+
+	assert.Equal(t, 3, groups[0].TimeToFirstReview.Count)
+	assert.Equal(t, 2, groups[0].TimeToApproval.Count)
+	assert.Nil(t, groups[0].TimeToMerge)
+	require.Len(t, groups[0].Series, 1)
+	assert.Equal(t, 3, groups[0].Series[0].TimeToFirstReview.Count)
+
+	assert.Nil(t, groups[1].TimeToFirstReview)
+	assert.Equal(t, 1, groups[1].TimeToMerge.Count)
+}
@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/tracing"
+)
+
+// validateWindow: период статистики должен быть непустым
+func validateWindow(window models.TimeWindow) error {
+	if window.From != nil && window.To != nil && !window.From.Before(*window.To) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// GetTurnaround - время до первого ревью, до одобрения и до мержа по командам или ревьюверам.
+// Ревью и одобрения берутся из событий review_submitted (SubmitReview)
+func (s *Service) GetTurnaround(ctx context.Context, filter models.TurnaroundFilter) ([]models.TurnaroundGroup, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetTurnaround", tracing.Team(filter.TeamName))
+	defer span.End()
+
+	if err := validateWindow(filter.TimeWindow); err != nil {
+		return nil, err
+	}
+	switch filter.GroupBy {
+	case "":
+		filter.GroupBy = models.TurnaroundByTeam
+	case models.TurnaroundByTeam, models.TurnaroundByReviewer:
+	default:
+		return nil, fmt.Errorf("%w: group_by must be team or reviewer", ErrInvalidRequest)
+	}
+	switch filter.Bucket {
+	case "", models.BucketDay, models.BucketWeek:
+	default:
+		return nil, fmt.Errorf("%w: bucket must be day or week", ErrInvalidRequest)
+	}
+
+	if filter.TeamName != "" {
+		exists, err := s.repo.TeamExists(ctx, filter.TeamName)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, ErrNotFound
+		}
+	}
+
+	rows, err := s.repo.GetTurnaround(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	// строки отсортированы по группе и метрике, итог группы идёт перед её бакетами
+	groups := []models.TurnaroundGroup{}
+	buckets := map[time.Time]int{}
+	for _, row := range rows {
+		if len(groups) == 0 || groups[len(groups)-1].Key != row.GroupKey {
+			groups = append(groups, models.TurnaroundGroup{Key: row.GroupKey})
+			buckets = map[time.Time]int{}
+		}
+		group := &groups[len(groups)-1]
+		stats := row.DurationStats
+
+		if row.BucketStart == nil {
+			setTurnaroundMetric(row.Metric, &stats, &group.TurnaroundMetrics)
+			continue
+		}
+		i, ok := buckets[*row.BucketStart]
+		if !ok {
+			i = len(group.Series)
+			buckets[*row.BucketStart] = i
+			group.Series = append(group.Series, models.TurnaroundBucket{Start: *row.BucketStart})
+		}
+		bucket := &group.Series[i]
+		setTurnaroundMetric(row.Metric, &stats, &bucket.TurnaroundMetrics)
+	}
+
+	for i := range groups {
+		sort.Slice(groups[i].Series, func(a, b int) bool {
+			return groups[i].Series[a].Start.Before(groups[i].Series[b].Start)
+		})
+	}
+	return groups, nil
+}
+
+func setTurnaroundMetric(metric string, stats *models.DurationStats, metrics *models.TurnaroundMetrics) {
+	switch metric {
+	case models.TurnaroundFirstReview:
+		metrics.TimeToFirstReview = stats
+	case models.TurnaroundApproval:
+		metrics.TimeToApproval = stats
+	case models.TurnaroundMerge:
+		metrics.TimeToMerge = stats
+	}
+}
+
+// defaultFairnessThreshold - допустимое отклонение доли назначений от равной (25%)
+const defaultFairnessThreshold = 0.25
+
+func WithFairnessThreshold(threshold float64) Option {
+	return func(s *Service) {
+		s.fairnessThreshold = threshold
+	}
+}
+
+// GetFairness сравнивает доли назначений участников команды за период с равной долей.
+// В расчёт входят активные участники и все, кто получал назначения в периоде
+func (s *Service) GetFairness(ctx context.Context, filter models.FairnessFilter) (*models.FairnessReport, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetFairness", tracing.Team(filter.TeamName))
+	defer span.End()
+
+	if filter.TeamName == "" {
+		return nil, fmt.Errorf("%w: team is required", ErrInvalidRequest)
+	}
+	if err := validateWindow(filter.TimeWindow); err != nil {
+		return nil, err
+	}
+	if filter.Threshold < 0 {
+		return nil, fmt.Errorf("%w: threshold must not be negative", ErrInvalidRequest)
+	}
+	if filter.Threshold == 0 {
+		filter.Threshold = s.fairnessThreshold
+	}
+
+	team, err := s.GetTeam(ctx, filter.TeamName)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := s.repo.GetReviewStats(ctx, []string{team.TeamName}, filter.TimeWindow, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	active := make(map[string]bool, len(team.Members))
+	for _, m := range team.Members {
+		active[m.UserID] = m.IsActive
+	}
+
+	report := &models.FairnessReport{
+		TeamName:  team.TeamName,
+		Threshold: filter.Threshold,
+		Members:   []models.FairnessMember{},
+	}
+	counts := []int{}
+	for _, stat := range stats {
+		if !active[stat.UserID] && stat.ReviewsAssigned == 0 {
+			continue
+		}
+		report.Members = append(report.Members, models.FairnessMember{ReviewStat: stat, IsActive: active[stat.UserID]})
+		counts = append(counts, stat.ReviewsAssigned)
+		report.TotalAssignments += stat.ReviewsAssigned
+	}
+	if len(counts) == 0 {
+		return report, nil
+	}
+	sort.SliceStable(report.Members, func(i, j int) bool {
+		return report.Members[i].ReviewsAssigned > report.Members[j].ReviewsAssigned
+	})
+
+	report.EqualShare = 1 / float64(len(counts))
+	report.Gini = gini(counts)
+	report.MaxMinRatio = maxMinRatio(counts)
+	if report.TotalAssignments == 0 {
+		return report, nil
+	}
+
+	for i := range report.Members {
+		m := &report.Members[i]
+		m.Share = float64(m.ReviewsAssigned) / float64(report.TotalAssignments)
+		m.Deviation = m.Share/report.EqualShare - 1
+		switch {
+		case m.Deviation > filter.Threshold:
+			m.Flag = models.FairnessOver
+		case m.Deviation < -filter.Threshold:
+			m.Flag = models.FairnessUnder
+		}
+	}
+	return report, nil
+}
+
+// gini - коэффициент Джини: 0 - назначения распределены поровну, ближе к 1 - всё у одного
+func gini(counts []int) float64 {
+	var total, diffs float64
+	for _, a := range counts {
+		total += float64(a)
+		for _, b := range counts {
+			diffs += math.Abs(float64(a - b))
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return diffs / (2 * float64(len(counts)) * total)
+}
+
+func maxMinRatio(counts []int) *float64 {
+	lowest, highest := counts[0], counts[0]
+	for _, c := range counts {
+		lowest = min(lowest, c)
+		highest = max(highest, c)
+	}
+	if lowest == 0 {
+		return nil
+	}
+	ratio := float64(highest) / float64(lowest)
+	return &ratio
+}
+
+// GetReviewPairs - матрица назначений автор -> ревьювер внутри команды за период
+func (s *Service) GetReviewPairs(ctx context.Context, filter models.PairFilter) ([]models.ReviewPair, error) {
+	ctx, span := tracing.Start(ctx, "Service.GetReviewPairs", tracing.Team(filter.TeamName))
+	defer span.End()
+
+	if filter.TeamName == "" {
+		return nil, fmt.Errorf("%w: team is required", ErrInvalidRequest)
+	}
+	if err := validateWindow(filter.TimeWindow); err != nil {
+		return nil, err
+	}
+	if _, err := s.GetTeam(ctx, filter.TeamName); err != nil {
+		return nil, err
+	}
+
+	pairs, err := s.repo.GetReviewPairs(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	perAuthor := map[string]int{}
+	for _, pair := range pairs {
+		perAuthor[pair.AuthorID] += pair.Count
+	}
+	for i := range pairs {
+		pairs[i].AuthorShare = float64(pairs[i].Count) / float64(perAuthor[pairs[i].AuthorID])
+	}
+	return pairs, nil
+}
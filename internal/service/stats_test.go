@@ -0,0 +1,23 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGini(t *testing.T) {
+	assert.Equal(t, 0.0, gini([]int{4, 4, 4}))
+	assert.Equal(t, 0.0, gini([]int{0, 0}))
+	assert.InDelta(t, 2.0/3.0, gini([]int{0, 0, 9}), 1e-9)
+	assert.InDelta(t, 1.0/3.0, gini([]int{0, 1, 1}), 1e-9)
+}
+
+func TestMaxMinRatio(t *testing.T) {
+	ratio := maxMinRatio([]int{2, 6, 3})
+	require.NotNil(t, ratio)
+	assert.Equal(t, 3.0, *ratio)
+
+	assert.Nil(t, maxMinRatio([]int{0, 5}))
+}
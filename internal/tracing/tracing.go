@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/denvyworking/pr-reviewer-service"
+
+// Экспортёры спанов
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+	ExporterOTLP   = "otlp"
+)
+
+// Config - куда отправлять спаны. Адрес OTLP и заголовки берутся из стандартных
+// переменных OTEL_EXPORTER_OTLP_*, имя сервиса можно переопределить OTEL_SERVICE_NAME
+type Config struct {
+	Exporter    string
+	File        string
+	SampleRatio float64
+}
+
+// Setup настраивает глобальный TracerProvider и распространение W3C trace context.
+// Возвращает функцию, которая дописывает оставшиеся спаны при остановке
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var opt sdktrace.TracerProviderOption
+	var closeFile func() error
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, err
+		}
+		// для отладки спаны пишутся сразу, чтобы не терять их при падении
+		opt = sdktrace.WithSyncer(exporter)
+	case ExporterFile:
+		if cfg.File == "" {
+			return nil, fmt.Errorf("file exporter requires a file path")
+		}
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+		opt = sdktrace.WithSyncer(exporter)
+		closeFile = file.Close
+	case ExporterOTLP:
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, err
+		}
+		opt = sdktrace.WithBatcher(exporter)
+	default:
+		return nil, fmt.Errorf("unknown exporter %q: must be none, stdout, file or otlp", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(
+		resource.Default(),
+		resource.NewSchemaless(semconv.ServiceName("pr-reviewer-service")),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// OTEL_SERVICE_NAME и OTEL_RESOURCE_ATTRIBUTES важнее имени по умолчанию
+	if res, err = resource.Merge(res, resource.Environment()); err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		opt,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closeFile != nil {
+			if closeErr := closeFile(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start открывает дочерний спан. До Setup глобальный провайдер ничего не записывает
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End закрывает спан, отмечая ошибку, если она есть
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// RepositoryHook открывает спан на каждый запрос репозитория (см. instrumented.Hook)
+func RepositoryHook(ctx context.Context, method string, attrs []attribute.KeyValue) (context.Context, func(error)) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "repository."+method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(append(attrs, semconv.DBSystemNamePostgreSQL)...),
+	)
+	return ctx, func(err error) { End(span, err) }
+}
+
+// Атрибуты предметной области для спанов
+func PRID(id string) attribute.KeyValue       { return attribute.String("pr.id", id) }
+func Team(name string) attribute.KeyValue     { return attribute.String("team.name", name) }
+func Teams(names []string) attribute.KeyValue { return attribute.StringSlice("team.names", names) }
+func User(id string) attribute.KeyValue       { return attribute.String("user.id", id) }
+func Users(ids []string) attribute.KeyValue   { return attribute.StringSlice("user.ids", ids) }
+func APIKey(id string) attribute.KeyValue     { return attribute.String("api_key.id", id) }
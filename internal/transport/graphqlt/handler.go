@@ -0,0 +1,87 @@
+package graphqlt
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/requestid"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// maxDepth ограничивает вложенность запроса (team -> children -> members -> ...)
+const maxDepth = 10
+
+// Handler обслуживает POST /graphql поверх того же service.Service, что и REST/gRPC.
+// Схема только на чтение; авторизацию маршрута выполняет HTTP-middleware
+type Handler struct {
+	schema  *graphql.Schema
+	service *service.Service
+}
+
+func NewHandler(svc *service.Service) *Handler {
+	schema := graphql.MustParseSchema(schemaSDL, &queryResolver{service: svc}, graphql.MaxDepth(maxDepth))
+	return &Handler{schema: schema, service: svc}
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Query == "" {
+		writeResponse(w, http.StatusBadRequest, map[string]interface{}{
+			"errors": []map[string]string{{"message": "request body must be JSON with a non-empty query"}},
+		})
+		return
+	}
+
+	ctx := withLoaders(r.Context(), newLoaders(h.service))
+	resp := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	sanitizeErrors(ctx, resp.Errors)
+
+	writeResponse(w, http.StatusOK, resp)
+}
+
+// sanitizeErrors заменяет текст внутренних ошибок резолверов общим сообщением с request_id,
+// а саму ошибку пишет в лог. Ошибки запроса и ожидаемые ошибки сервиса не меняются
+func sanitizeErrors(ctx context.Context, errs []*gqlerrors.QueryError) {
+	for _, qe := range errs {
+		if qe.ResolverError == nil || isClientError(qe.ResolverError) {
+			continue
+		}
+		slog.ErrorContext(ctx, "graphql resolver failed", "path", qe.Path, "error", qe.ResolverError)
+		qe.Message = "internal server error"
+		if qe.Extensions == nil {
+			qe.Extensions = map[string]any{}
+		}
+		qe.Extensions["request_id"] = requestid.FromContext(ctx)
+	}
+}
+
+func isClientError(err error) bool {
+	for _, target := range []error{service.ErrForbidden, service.ErrNotFound, service.ErrInvalidRequest, service.ErrUnauthorized} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func writeResponse(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,225 @@
+package graphqlt
+
+import (
+	"context"
+	"sync"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+type fetchFunc[K comparable, V any] func(ctx context.Context, keys []K) (map[K]V, error)
+
+// loader загружает недостающие ключи одним вызовом fetch и кеширует результаты
+// на время запроса, поэтому загрузчики создаются на каждый запрос. Пачки собираются
+// не по таймеру: резолвер списка заранее загружает ключи всех элементов уровнем ниже (prime*)
+type loader[K comparable, V any] struct {
+	fetch fetchFunc[K, V]
+
+	mu    sync.Mutex
+	cache map[K]*entry[V]
+}
+
+type entry[V any] struct {
+	done  chan struct{}
+	value V
+	found bool
+	err   error
+}
+
+func newLoader[K comparable, V any](fetch fetchFunc[K, V]) *loader[K, V] {
+	return &loader[K, V]{fetch: fetch, cache: make(map[K]*entry[V])}
+}
+
+// Load возвращает нулевое значение V, если ключ не найден
+func (l *loader[K, V]) Load(ctx context.Context, key K) (V, error) {
+	e := l.start(ctx, []K{key})[0]
+	<-e.done
+	return e.value, e.err
+}
+
+// LoadMany загружает все ключи одной пачкой; ненайденные пропускаются
+func (l *loader[K, V]) LoadMany(ctx context.Context, keys []K) ([]V, error) {
+	entries := l.start(ctx, keys)
+
+	values := make([]V, 0, len(keys))
+	for _, e := range entries {
+		<-e.done
+		if e.err != nil {
+			return nil, e.err
+		}
+		if e.found {
+			values = append(values, e.value)
+		}
+	}
+	return values, nil
+}
+
+// start возвращает записи кеша для keys, а отсутствующие в кеше загружает одним fetch.
+// Параллельный запрос тех же ключей ждёт уже начатую загрузку
+func (l *loader[K, V]) start(ctx context.Context, keys []K) []*entry[V] {
+	entries := make([]*entry[V], len(keys))
+	var missing []K
+	var fresh []*entry[V]
+
+	l.mu.Lock()
+	for i, key := range keys {
+		e, ok := l.cache[key]
+		if !ok {
+			e = &entry[V]{done: make(chan struct{})}
+			l.cache[key] = e
+			missing = append(missing, key)
+			fresh = append(fresh, e)
+		}
+		entries[i] = e
+	}
+	l.mu.Unlock()
+
+	if len(missing) == 0 {
+		return entries
+	}
+	results, err := l.fetch(ctx, missing)
+	for i, e := range fresh {
+		e.value, e.found = results[missing[i]]
+		e.err = err
+		close(e.done)
+	}
+	return entries
+}
+
+// loaders - набор загрузчиков одного запроса
+type loaders struct {
+	users          *loader[string, *models.User]
+	teams          *loader[string, *models.Team]
+	children       *loader[string, []string]
+	pendingReviews *loader[string, []models.PullRequest]
+}
+
+func newLoaders(svc *service.Service) *loaders {
+	return &loaders{
+		users: newLoader(func(ctx context.Context, ids []string) (map[string]*models.User, error) {
+			users, err := svc.GetUsersByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[string]*models.User, len(users))
+			for _, u := range users {
+				result[u.UserID] = u
+			}
+			return result, nil
+		}),
+		teams: newLoader(func(ctx context.Context, names []string) (map[string]*models.Team, error) {
+			teams, err := svc.GetTeamsByNames(ctx, names)
+			if err != nil {
+				return nil, err
+			}
+			result := make(map[string]*models.Team, len(teams))
+			for i := range teams {
+				result[teams[i].TeamName] = &teams[i]
+			}
+			return result, nil
+		}),
+		children:       newLoader(svc.GetChildTeams),
+		pendingReviews: newLoader(svc.GetPendingReviews),
+	}
+}
+
+// primeTeams заранее загружает поля, выбранные у списка команд по пути prefix:
+// детей, родителей и данные участников - по одному запросу на весь уровень
+func (l *loaders) primeTeams(ctx context.Context, prefix string, teams []*models.Team) error {
+	if len(teams) == 0 {
+		return nil
+	}
+	if graphql.HasSelectedField(ctx, prefix+"children") {
+		names := make([]string, 0, len(teams))
+		for _, team := range teams {
+			names = append(names, team.TeamName)
+		}
+		if _, err := l.children.LoadMany(ctx, names); err != nil {
+			return err
+		}
+	}
+	if graphql.HasSelectedField(ctx, prefix+"parent") {
+		var parents []string
+		for _, team := range teams {
+			if team.ParentTeam != "" {
+				parents = append(parents, team.ParentTeam)
+			}
+		}
+		if _, err := l.teams.LoadMany(ctx, parents); err != nil {
+			return err
+		}
+	}
+	if graphql.HasSelectedField(ctx, prefix+"members") && requirePermission(ctx, auth.PermUsersRead) == nil {
+		var members []*models.User
+		for _, team := range teams {
+			members = append(members, teamMembers(team)...)
+		}
+		return l.primeUsers(ctx, prefix+"members.", members)
+	}
+	return nil
+}
+
+// primeUsers заранее загружает команды и открытые ревью списка пользователей
+func (l *loaders) primeUsers(ctx context.Context, prefix string, users []*models.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	if graphql.HasSelectedField(ctx, prefix+"team") {
+		var names []string
+		for _, u := range users {
+			if u.TeamName != "" {
+				names = append(names, u.TeamName)
+			}
+		}
+		if _, err := l.teams.LoadMany(ctx, names); err != nil {
+			return err
+		}
+	}
+	pending := graphql.HasSelectedField(ctx, prefix+"pendingReviews") && requirePermission(ctx, auth.PermPRRead) == nil
+	count := graphql.HasSelectedField(ctx, prefix+"reviewCount") && requirePermission(ctx, auth.PermStatsRead) == nil
+	if pending || count {
+		ids := make([]string, 0, len(users))
+		for _, u := range users {
+			ids = append(ids, u.UserID)
+		}
+		if _, err := l.pendingReviews.LoadMany(ctx, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// primePRs заранее загружает авторов и ревьюверов списка PR; без users:read поля
+// всё равно вернут ошибку, поэтому пользователи не загружаются
+func (l *loaders) primePRs(ctx context.Context, prefix string, prs []models.PullRequest) error {
+	author := graphql.HasSelectedField(ctx, prefix+"author")
+	reviewers := graphql.HasSelectedField(ctx, prefix+"reviewers")
+	if (!author && !reviewers) || requirePermission(ctx, auth.PermUsersRead) != nil {
+		return nil
+	}
+	var ids []string
+	for _, pr := range prs {
+		if author {
+			ids = append(ids, pr.AuthorID)
+		}
+		if reviewers {
+			ids = append(ids, pr.AssignedReviewers...)
+		}
+	}
+	_, err := l.users.LoadMany(ctx, ids)
+	return err
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,78 @@
+package graphqlt
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+func TestLoaderBatchesLoadMany(t *testing.T) {
+	var calls atomic.Int32
+	l := newLoader(func(_ context.Context, keys []string) (map[string]int, error) {
+		calls.Add(1)
+		result := make(map[string]int, len(keys))
+		for _, k := range keys {
+			if k != "missing" {
+				result[k] = len(k)
+			}
+		}
+		return result, nil
+	})
+
+	many, err := l.LoadMany(context.Background(), []string{"a", "bb", "ccc", "a", "missing"})
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 2, 3, 1}, many)
+	assert.Equal(t, int32(1), calls.Load())
+
+	// резолверы элементов после подготовки уровня берут значения из кеша запроса
+	var wg sync.WaitGroup
+	for _, key := range []string{"a", "bb", "missing"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := l.Load(context.Background(), key)
+			assert.NoError(t, err)
+			if key != "missing" {
+				assert.Equal(t, len(key), v)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+
+	v, err := l.Load(context.Background(), "dddd")
+	require.NoError(t, err)
+	assert.Equal(t, 4, v)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestMembersRequireUsersRead(t *testing.T) {
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Role: auth.RoleBot})
+	team := &teamResolver{team: &models.Team{TeamName: "backend", Members: []models.TeamMember{{UserID: "u1"}}}}
+
+	_, err := team.Members(ctx)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	// у bot есть pr:read и stats:read, но пользователи через PR и статистику тоже закрыты
+	pr := &pullRequestResolver{pr: &models.PullRequest{AuthorID: "u1", AssignedReviewers: []string{"u2"}}}
+	_, err = pr.Author(ctx)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+	_, err = pr.Reviewers(ctx)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+
+	stat := &reviewStatResolver{stat: models.ReviewStat{UserID: "u1"}}
+	_, err = stat.User(ctx)
+	assert.ErrorIs(t, err, service.ErrForbidden)
+}
+
+func TestSchemaMatchesResolvers(t *testing.T) {
+	assert.NotPanics(t, func() { NewHandler(nil) })
+}
@@ -0,0 +1,321 @@
+package graphqlt
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	graphql "github.com/graph-gophers/graphql-go"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// requirePermission проверяет право на уровне поля: маршрут /graphql пускает с teams:read,
+// а пользователи, PR и статистика требуют тех же прав, что и их REST-аналоги
+func requirePermission(ctx context.Context, perm auth.Permission) error {
+	principal := auth.PrincipalFromContext(ctx)
+	if principal == nil || !principal.Can(perm) {
+		return service.ErrForbidden
+	}
+	return nil
+}
+
+type queryResolver struct {
+	service *service.Service
+}
+
+func (q *queryResolver) Team(ctx context.Context, args struct{ Name string }) (*teamResolver, error) {
+	l := loadersFrom(ctx)
+	team, err := l.teams.Load(ctx, args.Name)
+	if err != nil || team == nil {
+		return nil, err
+	}
+	if err := l.primeTeams(ctx, "", []*models.Team{team}); err != nil {
+		return nil, err
+	}
+	return &teamResolver{team: team}, nil
+}
+
+func (q *queryResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	if err := requirePermission(ctx, auth.PermUsersRead); err != nil {
+		return nil, err
+	}
+	user, err := loadersFrom(ctx).users.Load(ctx, string(args.ID))
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return &userResolver{user: user}, nil
+}
+
+func (q *queryResolver) PullRequest(ctx context.Context, args struct{ ID graphql.ID }) (*pullRequestResolver, error) {
+	if err := requirePermission(ctx, auth.PermPRRead); err != nil {
+		return nil, err
+	}
+	pr, err := q.service.GetPR(ctx, string(args.ID))
+	if errors.Is(err, service.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pullRequestResolver{pr: pr}, nil
+}
+
+func (q *queryResolver) ReviewStats(ctx context.Context, args struct {
+	Team    *string
+	Subtree bool
+	Limit   *int32
+}) ([]*reviewStatResolver, error) {
+	if err := requirePermission(ctx, auth.PermStatsRead); err != nil {
+		return nil, err
+	}
+
+	filter := models.ReviewStatsFilter{Subtree: args.Subtree}
+	if args.Team != nil {
+		filter.TeamName = *args.Team
+	}
+
+	if args.Limit != nil {
+		filter.Limit = int(*args.Limit)
+	}
+
+	// курсор в списочном поле не вернуть, поэтому только первая страница размером limit
+	stats, _, _, err := q.service.GetReviewStats(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if graphql.HasSelectedField(ctx, "user") && requirePermission(ctx, auth.PermUsersRead) == nil {
+		l := loadersFrom(ctx)
+		ids := make([]string, 0, len(stats))
+		for _, stat := range stats {
+			ids = append(ids, stat.UserID)
+		}
+		users, err := l.users.LoadMany(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if err := l.primeUsers(ctx, "user.", users); err != nil {
+			return nil, err
+		}
+	}
+
+	resolvers := make([]*reviewStatResolver, 0, len(stats))
+	for _, stat := range stats {
+		resolvers = append(resolvers, &reviewStatResolver{stat: stat})
+	}
+	return resolvers, nil
+}
+
+type teamResolver struct {
+	team *models.Team
+}
+
+func (t *teamResolver) Name() string {
+	return t.team.TeamName
+}
+
+func (t *teamResolver) Parent(ctx context.Context) (*teamResolver, error) {
+	if t.team.ParentTeam == "" {
+		return nil, nil
+	}
+	parent, err := loadersFrom(ctx).teams.Load(ctx, t.team.ParentTeam)
+	if err != nil || parent == nil {
+		return nil, err
+	}
+	return &teamResolver{team: parent}, nil
+}
+
+func (t *teamResolver) Children(ctx context.Context) ([]*teamResolver, error) {
+	l := loadersFrom(ctx)
+
+	names, err := l.children.Load(ctx, t.team.TeamName)
+	if err != nil {
+		return nil, err
+	}
+	teams, err := l.teams.LoadMany(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.primeTeams(ctx, "", teams); err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*teamResolver, 0, len(teams))
+	for _, team := range teams {
+		resolvers = append(resolvers, &teamResolver{team: team})
+	}
+	return resolvers, nil
+}
+
+// Members не ходит в базу: участники приходят вместе с командой
+func (t *teamResolver) Members(ctx context.Context) ([]*userResolver, error) {
+	if err := requirePermission(ctx, auth.PermUsersRead); err != nil {
+		return nil, err
+	}
+	members := teamMembers(t.team)
+	if err := loadersFrom(ctx).primeUsers(ctx, "", members); err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*userResolver, 0, len(members))
+	for _, m := range members {
+		resolvers = append(resolvers, &userResolver{user: m})
+	}
+	return resolvers, nil
+}
+
+func teamMembers(team *models.Team) []*models.User {
+	users := make([]*models.User, 0, len(team.Members))
+	for _, m := range team.Members {
+		users = append(users, &models.User{
+			UserID:   m.UserID,
+			Username: m.Username,
+			TeamName: team.TeamName,
+			IsActive: m.IsActive,
+		})
+	}
+	return users
+}
+
+type userResolver struct {
+	user *models.User
+}
+
+func (u *userResolver) ID() graphql.ID {
+	return graphql.ID(u.user.UserID)
+}
+
+func (u *userResolver) Username() string {
+	return u.user.Username
+}
+
+func (u *userResolver) IsActive() bool {
+	return u.user.IsActive
+}
+
+func (u *userResolver) Team(ctx context.Context) (*teamResolver, error) {
+	team, err := loadersFrom(ctx).teams.Load(ctx, u.user.TeamName)
+	if err != nil || team == nil {
+		return nil, err
+	}
+	return &teamResolver{team: team}, nil
+}
+
+func (u *userResolver) PendingReviews(ctx context.Context) ([]*pullRequestResolver, error) {
+	if err := requirePermission(ctx, auth.PermPRRead); err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	prs, err := l.pendingReviews.Load(ctx, u.user.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.primePRs(ctx, "", prs); err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*pullRequestResolver, 0, len(prs))
+	for i := range prs {
+		resolvers = append(resolvers, &pullRequestResolver{pr: &prs[i]})
+	}
+	return resolvers, nil
+}
+
+// ReviewCount считается так же, как в /stats/review-counts - по открытым PR
+func (u *userResolver) ReviewCount(ctx context.Context) (int32, error) {
+	if err := requirePermission(ctx, auth.PermStatsRead); err != nil {
+		return 0, err
+	}
+	prs, err := loadersFrom(ctx).pendingReviews.Load(ctx, u.user.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return int32(len(prs)), nil
+}
+
+type pullRequestResolver struct {
+	pr *models.PullRequest
+}
+
+func (p *pullRequestResolver) ID() graphql.ID {
+	return graphql.ID(p.pr.PullRequestID)
+}
+
+func (p *pullRequestResolver) Name() string {
+	return p.pr.PullRequestName
+}
+
+func (p *pullRequestResolver) Status() string {
+	return string(p.pr.Status)
+}
+
+func (p *pullRequestResolver) Author(ctx context.Context) (*userResolver, error) {
+	if err := requirePermission(ctx, auth.PermUsersRead); err != nil {
+		return nil, err
+	}
+	author, err := loadersFrom(ctx).users.Load(ctx, p.pr.AuthorID)
+	if err != nil || author == nil {
+		return nil, err
+	}
+	return &userResolver{user: author}, nil
+}
+
+func (p *pullRequestResolver) Reviewers(ctx context.Context) ([]*userResolver, error) {
+	if err := requirePermission(ctx, auth.PermUsersRead); err != nil {
+		return nil, err
+	}
+	l := loadersFrom(ctx)
+	users, err := l.users.LoadMany(ctx, p.pr.AssignedReviewers)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.primeUsers(ctx, "", users); err != nil {
+		return nil, err
+	}
+
+	resolvers := make([]*userResolver, 0, len(users))
+	for _, u := range users {
+		resolvers = append(resolvers, &userResolver{user: u})
+	}
+	return resolvers, nil
+}
+
+func (p *pullRequestResolver) CreatedAt() *string {
+	return formatTime(p.pr.CreatedAt)
+}
+
+func (p *pullRequestResolver) MergedAt() *string {
+	return formatTime(p.pr.MergedAt)
+}
+
+type reviewStatResolver struct {
+	stat models.ReviewStat
+}
+
+func (r *reviewStatResolver) User(ctx context.Context) (*userResolver, error) {
+	if err := requirePermission(ctx, auth.PermUsersRead); err != nil {
+		return nil, err
+	}
+	user, err := loadersFrom(ctx).users.Load(ctx, r.stat.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user = &models.User{UserID: r.stat.UserID, Username: r.stat.Username}
+	}
+	return &userResolver{user: user}, nil
+}
+
+func (r *reviewStatResolver) ReviewCount() int32 {
+	return int32(r.stat.ReviewCount)
+}
+
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format(time.RFC3339)
+	return &s
+}
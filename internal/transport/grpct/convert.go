@@ -0,0 +1,146 @@
+package grpct
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	reviewerv1 "github.com/denvyworking/pr-reviewer-service/api/reviewer/v1"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+func teamFromProto(team *reviewerv1.Team) *models.Team {
+	result := &models.Team{
+		TeamName:   team.GetTeamName(),
+		ParentTeam: team.GetParentTeam(),
+		Members:    make([]models.TeamMember, 0, len(team.GetMembers())),
+	}
+	for _, member := range team.GetMembers() {
+		result.Members = append(result.Members, models.TeamMember{
+			UserID:   member.GetUserId(),
+			Username: member.GetUsername(),
+			IsActive: member.GetIsActive(),
+		})
+	}
+	return result
+}
+
+func teamToProto(team *models.Team) *reviewerv1.Team {
+	result := &reviewerv1.Team{
+		TeamName:   team.TeamName,
+		ParentTeam: team.ParentTeam,
+	}
+	for _, member := range team.Members {
+		result.Members = append(result.Members, &reviewerv1.TeamMember{
+			UserId:   member.UserID,
+			Username: member.Username,
+			IsActive: member.IsActive,
+		})
+	}
+	return result
+}
+
+func userToProto(user *models.User) *reviewerv1.User {
+	return &reviewerv1.User{
+		UserId:   user.UserID,
+		Username: user.Username,
+		TeamName: user.TeamName,
+		IsActive: user.IsActive,
+	}
+}
+
+func statusToProto(status models.PullRequestStatus) reviewerv1.PullRequestStatus {
+	switch status {
+	case models.StatusOpen:
+		return reviewerv1.PullRequestStatus_PULL_REQUEST_STATUS_OPEN
+	case models.StatusMerged:
+		return reviewerv1.PullRequestStatus_PULL_REQUEST_STATUS_MERGED
+	case models.StatusClosed:
+		return reviewerv1.PullRequestStatus_PULL_REQUEST_STATUS_CLOSED
+	default:
+		return reviewerv1.PullRequestStatus_PULL_REQUEST_STATUS_UNSPECIFIED
+	}
+}
+
+func statusFromProto(status reviewerv1.PullRequestStatus) models.PullRequestStatus {
+	switch status {
+	case reviewerv1.PullRequestStatus_PULL_REQUEST_STATUS_OPEN:
+		return models.StatusOpen
+	case reviewerv1.PullRequestStatus_PULL_REQUEST_STATUS_MERGED:
+		return models.StatusMerged
+	case reviewerv1.PullRequestStatus_PULL_REQUEST_STATUS_CLOSED:
+		return models.StatusClosed
+	default:
+		return ""
+	}
+}
+
+func timestampFromProto(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func timestampToProto(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func pullRequestToProto(pr *models.PullRequest) *reviewerv1.PullRequest {
+	return &reviewerv1.PullRequest{
+		PullRequestId:     pr.PullRequestID,
+		PullRequestName:   pr.PullRequestName,
+		AuthorId:          pr.AuthorID,
+		Status:            statusToProto(pr.Status),
+		AssignedReviewers: pr.AssignedReviewers,
+		CreatedAt:         timestampToProto(pr.CreatedAt),
+		MergedAt:          timestampToProto(pr.MergedAt),
+	}
+}
+
+func pullRequestShortToProto(pr models.PullRequestShort) *reviewerv1.PullRequestShort {
+	return &reviewerv1.PullRequestShort{
+		PullRequestId:   pr.PullRequestID,
+		PullRequestName: pr.PullRequestName,
+		AuthorId:        pr.AuthorID,
+		Status:          statusToProto(pr.Status),
+		CreatedAt:       timestampToProto(pr.CreatedAt),
+	}
+}
+
+func prEventToProto(event models.PREvent) *reviewerv1.PullRequestEvent {
+	return &reviewerv1.PullRequestEvent{
+		Id:        event.ID,
+		EventType: string(event.EventType),
+		UserId:    event.UserID,
+		Cause:     string(event.Cause),
+		Verdict:   string(event.Verdict),
+		Actor:     event.Actor,
+		CreatedAt: timestamppb.New(event.CreatedAt),
+	}
+}
+
+func reviewStatToProto(stat models.ReviewStat) *reviewerv1.ReviewStat {
+	return &reviewerv1.ReviewStat{
+		UserId:           stat.UserID,
+		Username:         stat.Username,
+		ReviewCount:      int32(stat.ReviewCount),
+		ReviewsAssigned:  int32(stat.ReviewsAssigned),
+		ReviewsCompleted: int32(stat.ReviewsCompleted),
+		PrsAuthored:      int32(stat.PRsAuthored),
+		ReassignedAway:   int32(stat.ReassignedAway),
+	}
+}
+
+func teamReviewStatToProto(stat models.TeamReviewStat) *reviewerv1.TeamReviewStat {
+	return &reviewerv1.TeamReviewStat{
+		TeamName:         stat.TeamName,
+		ParentTeam:       stat.ParentTeam,
+		ReviewCount:      int32(stat.ReviewCount),
+		TotalReviewCount: int32(stat.TotalReviewCount),
+	}
+}
@@ -0,0 +1,136 @@
+package grpct
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	reviewerv1 "github.com/denvyworking/pr-reviewer-service/api/reviewer/v1"
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/requestid"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// methodPermissions - те же права, что и у соответствующих HTTP-маршрутов
+var methodPermissions = map[string]auth.Permission{
+	reviewerv1.ReviewerService_CreateTeam_FullMethodName:            auth.PermTeamsWrite,
+	reviewerv1.ReviewerService_GetTeam_FullMethodName:               auth.PermTeamsRead,
+	reviewerv1.ReviewerService_SetTeamParent_FullMethodName:         auth.PermTeamsWrite,
+	reviewerv1.ReviewerService_SetUserActivity_FullMethodName:       auth.PermUsersAdmin,
+	reviewerv1.ReviewerService_GetUserReviews_FullMethodName:        auth.PermPRRead,
+	reviewerv1.ReviewerService_BulkDeactivateUsers_FullMethodName:   auth.PermUsersAdmin,
+	reviewerv1.ReviewerService_CreatePullRequest_FullMethodName:     auth.PermPRWrite,
+	reviewerv1.ReviewerService_GetPullRequest_FullMethodName:        auth.PermPRRead,
+	reviewerv1.ReviewerService_MergePullRequest_FullMethodName:      auth.PermPRWrite,
+	reviewerv1.ReviewerService_ReassignReviewer_FullMethodName:      auth.PermPRWrite,
+	reviewerv1.ReviewerService_ClosePullRequest_FullMethodName:      auth.PermPRWrite,
+	reviewerv1.ReviewerService_SubmitReview_FullMethodName:          auth.PermPRWrite,
+	reviewerv1.ReviewerService_GetPullRequestHistory_FullMethodName: auth.PermPRRead,
+	reviewerv1.ReviewerService_GetReviewStats_FullMethodName:        auth.PermStatsRead,
+}
+
+func requestIDInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	id := firstMetadata(ctx, strings.ToLower(requestid.Header))
+	if id == "" || len(id) > 100 {
+		id = requestid.Generate()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(strings.ToLower(requestid.Header), id))
+	return handler(requestid.NewContext(ctx, id), req)
+}
+
+// authInterceptor принимает API-ключ или JWT из метаданных authorization
+func (s *Server) authInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	perm, ok := methodPermissions[info.FullMethod]
+	if !ok {
+		return nil, status.Error(codes.PermissionDenied, "method is not allowed")
+	}
+
+	token := firstMetadata(ctx, "authorization")
+	if bearer, ok := strings.CutPrefix(token, "Bearer "); ok {
+		token = strings.TrimSpace(bearer)
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing credentials")
+	}
+
+	principal, err := s.authenticate(ctx, token)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	if !principal.Can(perm) {
+		return nil, status.Error(codes.PermissionDenied, "missing permission "+string(perm))
+	}
+
+	return handler(auth.WithPrincipal(ctx, principal), req)
+}
+
+func (s *Server) authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	if s.jwt == nil || !auth.LooksLikeJWT(token) {
+		return s.service.Authenticate(ctx, token)
+	}
+
+	claims, err := s.jwt.Verify(ctx, token)
+	if err != nil {
+		return nil, service.ErrUnauthorized
+	}
+	return s.service.AuthenticateUser(ctx, claims.Subject)
+}
+
+func errorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return resp, nil
+}
+
+// toStatus переводит ошибки сервиса в коды gRPC; текст внутренних ошибок остаётся в логе,
+// клиент получает только request_id для поиска записи
+func toStatus(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidRequest):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrUnauthorized):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, service.ErrForbidden):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrTeamExists), errors.Is(err, service.ErrPRExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrPRMerged),
+		errors.Is(err, service.ErrPRClosed),
+		errors.Is(err, service.ErrNotAssigned),
+		errors.Is(err, service.ErrNoCandidate),
+		errors.Is(err, service.ErrTeamCycle),
+		errors.Is(err, service.ErrBulkDeactivateFailed):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrConflict):
+		return status.Error(codes.Aborted, err.Error())
+	default:
+		slog.ErrorContext(ctx, "request failed", "error", err)
+		return status.Error(codes.Internal, "internal server error (request_id "+requestid.FromContext(ctx)+")")
+	}
+}
+
+func firstMetadata(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
@@ -0,0 +1,184 @@
+package grpct
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	reviewerv1 "github.com/denvyworking/pr-reviewer-service/api/reviewer/v1"
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// Server реализует reviewer.v1.ReviewerService поверх того же service.Service, что и HTTP
+type Server struct {
+	reviewerv1.UnimplementedReviewerServiceServer
+	service *service.Service
+	jwt     *auth.JWTVerifier
+}
+
+// NewServer: jwt может быть nil - тогда принимаются только API-ключи
+func NewServer(service *service.Service, jwt *auth.JWTVerifier) *grpc.Server {
+	s := &Server{service: service, jwt: jwt}
+
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		requestIDInterceptor,
+		s.authInterceptor,
+		errorInterceptor,
+	))
+	reviewerv1.RegisterReviewerServiceServer(srv, s)
+
+	return srv
+}
+
+func (s *Server) CreateTeam(ctx context.Context, req *reviewerv1.CreateTeamRequest) (*reviewerv1.CreateTeamResponse, error) {
+	team := teamFromProto(req.GetTeam())
+	if err := s.service.CreateTeam(ctx, team); err != nil {
+		return nil, err
+	}
+	return &reviewerv1.CreateTeamResponse{Team: teamToProto(team)}, nil
+}
+
+func (s *Server) GetTeam(ctx context.Context, req *reviewerv1.GetTeamRequest) (*reviewerv1.GetTeamResponse, error) {
+	team, err := s.service.GetTeam(ctx, req.GetTeamName())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.GetTeamResponse{Team: teamToProto(team)}, nil
+}
+
+func (s *Server) SetTeamParent(ctx context.Context, req *reviewerv1.SetTeamParentRequest) (*reviewerv1.SetTeamParentResponse, error) {
+	team, err := s.service.SetTeamParent(ctx, req.GetTeamName(), req.GetParentTeam())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.SetTeamParentResponse{Team: teamToProto(team)}, nil
+}
+
+func (s *Server) SetUserActivity(ctx context.Context, req *reviewerv1.SetUserActivityRequest) (*reviewerv1.SetUserActivityResponse, error) {
+	user, err := s.service.SetUserActivity(ctx, req.GetUserId(), req.GetIsActive())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.SetUserActivityResponse{User: userToProto(user)}, nil
+}
+
+func (s *Server) GetUserReviews(ctx context.Context, req *reviewerv1.GetUserReviewsRequest) (*reviewerv1.GetUserReviewsResponse, error) {
+	userID := req.GetUserId()
+	if principal := auth.PrincipalFromContext(ctx); userID == "" && principal != nil {
+		userID = principal.UserID
+	}
+
+	filter := models.ReviewFilter{
+		Status:        statusFromProto(req.GetStatus()),
+		CreatedAfter:  timestampFromProto(req.GetCreatedAfter()),
+		CreatedBefore: timestampFromProto(req.GetCreatedBefore()),
+		Ascending:     req.GetAscending(),
+		Limit:         int(req.GetPageSize()),
+	}
+	prs, next, err := s.service.ListReviews(ctx, userID, filter, req.GetPageToken())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &reviewerv1.GetUserReviewsResponse{UserId: userID, NextPageToken: next}
+	for _, pr := range prs {
+		resp.PullRequests = append(resp.PullRequests, pullRequestShortToProto(pr))
+	}
+	return resp, nil
+}
+
+func (s *Server) BulkDeactivateUsers(ctx context.Context, req *reviewerv1.BulkDeactivateUsersRequest) (*reviewerv1.BulkDeactivateUsersResponse, error) {
+	deactivated, err := s.service.BulkDeactivateUsers(ctx, req.GetUserIds())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.BulkDeactivateUsersResponse{DeactivatedUsers: deactivated}, nil
+}
+
+func (s *Server) CreatePullRequest(ctx context.Context, req *reviewerv1.CreatePullRequestRequest) (*reviewerv1.CreatePullRequestResponse, error) {
+	pr, err := s.service.CreatePR(ctx, req.GetPullRequestId(), req.GetPullRequestName(), req.GetAuthorId())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.CreatePullRequestResponse{Pr: pullRequestToProto(pr)}, nil
+}
+
+func (s *Server) GetPullRequest(ctx context.Context, req *reviewerv1.GetPullRequestRequest) (*reviewerv1.GetPullRequestResponse, error) {
+	pr, err := s.service.GetPR(ctx, req.GetPullRequestId())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.GetPullRequestResponse{Pr: pullRequestToProto(pr)}, nil
+}
+
+func (s *Server) MergePullRequest(ctx context.Context, req *reviewerv1.MergePullRequestRequest) (*reviewerv1.MergePullRequestResponse, error) {
+	pr, err := s.service.MergePR(ctx, req.GetPullRequestId())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.MergePullRequestResponse{Pr: pullRequestToProto(pr)}, nil
+}
+
+func (s *Server) ClosePullRequest(ctx context.Context, req *reviewerv1.ClosePullRequestRequest) (*reviewerv1.ClosePullRequestResponse, error) {
+	pr, err := s.service.ClosePR(ctx, req.GetPullRequestId())
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.ClosePullRequestResponse{Pr: pullRequestToProto(pr)}, nil
+}
+
+func (s *Server) SubmitReview(ctx context.Context, req *reviewerv1.SubmitReviewRequest) (*reviewerv1.SubmitReviewResponse, error) {
+	event, err := s.service.SubmitReview(ctx, req.GetPullRequestId(), req.GetReviewerId(), models.ReviewVerdict(req.GetVerdict()))
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.SubmitReviewResponse{Event: prEventToProto(*event)}, nil
+}
+
+func (s *Server) ReassignReviewer(ctx context.Context, req *reviewerv1.ReassignReviewerRequest) (*reviewerv1.ReassignReviewerResponse, error) {
+	pr, newReviewer, err := s.service.ReassignReviewer(ctx, req.GetPullRequestId(), req.GetOldUserId(), models.AssignmentCause(req.GetCause()))
+	if err != nil {
+		return nil, err
+	}
+	return &reviewerv1.ReassignReviewerResponse{Pr: pullRequestToProto(pr), ReplacedBy: newReviewer}, nil
+}
+
+func (s *Server) GetPullRequestHistory(ctx context.Context, req *reviewerv1.GetPullRequestHistoryRequest) (*reviewerv1.GetPullRequestHistoryResponse, error) {
+	events, err := s.service.GetPRHistory(ctx, req.GetPullRequestId())
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &reviewerv1.GetPullRequestHistoryResponse{PullRequestId: req.GetPullRequestId()}
+	for _, event := range events {
+		resp.Events = append(resp.Events, prEventToProto(event))
+	}
+	return resp, nil
+}
+
+func (s *Server) GetReviewStats(ctx context.Context, req *reviewerv1.GetReviewStatsRequest) (*reviewerv1.GetReviewStatsResponse, error) {
+	stats, teamStats, next, err := s.service.GetReviewStats(ctx, models.ReviewStatsFilter{
+		TeamName: req.GetTeam(),
+		Subtree:  req.GetSubtree(),
+		Cursor:   req.GetPageToken(),
+		Limit:    int(req.GetPageSize()),
+		TimeWindow: models.TimeWindow{
+			From: timestampFromProto(req.GetFrom()),
+			To:   timestampFromProto(req.GetTo()),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &reviewerv1.GetReviewStatsResponse{NextPageToken: next}
+	for _, stat := range stats {
+		resp.Stats = append(resp.Stats, reviewStatToProto(stat))
+	}
+	for _, stat := range teamStats {
+		resp.Teams = append(resp.Teams, teamReviewStatToProto(stat))
+	}
+	return resp, nil
+}
@@ -0,0 +1,167 @@
+package httpt
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// APIKeysHandler обслуживает /admin/api-keys: POST - создать, GET - список, DELETE - отозвать
+func (h *Handlers) APIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		h.createAPIKey(w, r)
+	case http.MethodGet:
+		h.listAPIKeys(w, r)
+	case http.MethodDelete:
+		h.revokeAPIKey(w, r)
+	default:
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handlers) createAPIKey(w http.ResponseWriter, r *http.Request) {
+	var request models.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	key, secret, err := h.service.CreateAPIKey(r.Context(), request)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRequest):
+			writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		case errors.Is(err, service.ErrNotFound):
+			writeError(w, "NOT_FOUND", "user not found", http.StatusNotFound)
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	// секрет показывается только в этом ответе
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_key": key,
+		"secret":  secret,
+	})
+}
+
+func (h *Handlers) listAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListAPIKeys(r.Context())
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_keys": keys,
+	})
+}
+
+func (h *Handlers) revokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyID := r.URL.Query().Get("key_id")
+	if keyID == "" {
+		writeError(w, "BAD_REQUEST", "key_id is required", http.StatusBadRequest)
+		return
+	}
+	h.revokeAPIKeyByID(w, r, keyID)
+}
+
+func (h *Handlers) revokeAPIKeyByID(w http.ResponseWriter, r *http.Request, keyID string) {
+	key, err := h.service.RevokeAPIKey(r.Context(), keyID)
+	if err != nil {
+		switch err {
+		case service.ErrNotFound:
+			writeError(w, "NOT_FOUND", "api key not found", http.StatusNotFound)
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"api_key": key,
+	})
+}
+
+// AuditHandler - GET /admin/audit с фильтрами actor, action, target_type, target_id,
+// from/to (RFC 3339) и пагинацией limit/cursor
+func (h *Handlers) AuditHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.AuditFilter{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		TargetType: query.Get("target_type"),
+		TargetID:   query.Get("target_id"),
+	}
+
+	var err error
+	if filter.From, err = parseTimeParam(query.Get("from")); err != nil {
+		writeError(w, "BAD_REQUEST", "from must be RFC 3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	if filter.To, err = parseTimeParam(query.Get("to")); err != nil {
+		writeError(w, "BAD_REQUEST", "to must be RFC 3339 timestamp", http.StatusBadRequest)
+		return
+	}
+	if limit := query.Get("limit"); limit != "" {
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			writeError(w, "BAD_REQUEST", "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+	}
+	if cursor := query.Get("cursor"); cursor != "" {
+		if filter.BeforeID, err = strconv.ParseInt(cursor, 10, 64); err != nil || filter.BeforeID <= 0 {
+			writeError(w, "BAD_REQUEST", "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries, next, err := h.service.ListAuditEntries(r.Context(), filter)
+	if err != nil {
+		writeInternalError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"entries": entries,
+	}
+	if next != 0 {
+		response["next_cursor"] = strconv.FormatInt(next, 10)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// parseTimeParam разбирает время RFC 3339 и приводит его к UTC: колонки TIMESTAMP
+// хранят время без зоны, и lib/pq при передаче отбрасывает смещение
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+	t = t.UTC()
+	return &t, nil
+}
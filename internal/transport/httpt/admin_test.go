@@ -0,0 +1,23 @@
+package httpt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeParamConvertsToUTC(t *testing.T) {
+	parsed, err := parseTimeParam("2026-01-01T00:00:00+03:00")
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, parsed.Location())
+	assert.Equal(t, time.Date(2025, 12, 31, 21, 0, 0, 0, time.UTC), *parsed)
+
+	parsed, err = parseTimeParam("")
+	require.NoError(t, err)
+	assert.Nil(t, parsed)
+
+	_, err = parseTimeParam("2026-01-01")
+	assert.Error(t, err)
+}
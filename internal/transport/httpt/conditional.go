@@ -0,0 +1,58 @@
+package httpt
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// Условные запросы: ETag - версия строки PR или команды в кавычках. Версия растёт
+// при каждом изменении, поэтому тег сильный и годится и для If-None-Match, и для If-Match
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// notModified ставит ETag ответа и отвечает 304, если If-None-Match совпал с ним.
+// true - ответ уже отправлен
+func notModified(w http.ResponseWriter, r *http.Request, version int64) bool {
+	tag := etag(version)
+	w.Header().Set("ETag", tag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	// для If-None-Match сравнение слабое: W/"3" совпадает с "3"
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == tag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// withIfMatch переносит If-Match в контекст сервиса, который проверит версию PR атомарно
+// с изменением. Поддерживаются один ETag или "*"; false - ответ об ошибке уже отправлен
+func withIfMatch(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return r, true
+	}
+	if strings.Contains(header, ",") {
+		writeError(w, "BAD_REQUEST", "If-Match must contain a single ETag", http.StatusBadRequest)
+		return nil, false
+	}
+
+	// слабый или чужой тег не совпадает ни с одной версией при сильном сравнении
+	version, err := strconv.ParseInt(strings.Trim(header, `"`), 10, 64)
+	if strings.HasPrefix(header, "W/") || err != nil || version <= 0 {
+		writeServiceError(w, r, service.ErrPreconditionFailed)
+		return nil, false
+	}
+	return r.WithContext(service.WithExpectedVersion(r.Context(), version)), true
+}
@@ -0,0 +1,258 @@
+package httpt
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+// Выгрузки в CSV и NDJSON. Формат задаёт format=csv|ndjson, без него - заголовок Accept,
+// по умолчанию CSV. Строки пишутся по мере чтения из базы, поэтому ошибка после первой
+// строки уже не может стать кодом ответа - соединение обрывается, чтобы клиент не принял
+// усечённый файл за полный
+
+const (
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	// через сколько строк сбрасывать буфер клиенту
+	exportFlushEvery = 500
+)
+
+func exportFormat(r *http.Request) (string, bool) {
+	switch format := r.URL.Query().Get("format"); format {
+	case formatCSV, formatNDJSON:
+		return format, true
+	case "":
+	default:
+		return "", false
+	}
+
+	for _, accepted := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return formatCSV, true
+		case "application/x-ndjson", "application/jsonl":
+			return formatNDJSON, true
+		}
+	}
+	return formatCSV, true
+}
+
+// exportWriter пишет записи в выбранном формате; заголовки ответа отправляются
+// вместе с первой строкой, чтобы до неё ещё можно было ответить ошибкой
+type exportWriter struct {
+	w        http.ResponseWriter
+	r        *http.Request
+	format   string
+	filename string
+	header   []string
+
+	csv     *csv.Writer
+	json    *json.Encoder
+	flusher http.Flusher
+	rows    int
+}
+
+func newExportWriter(w http.ResponseWriter, r *http.Request, format, name string, header []string) *exportWriter {
+	flusher, _ := w.(http.Flusher)
+	return &exportWriter{
+		w:        w,
+		r:        r,
+		format:   format,
+		filename: name + "." + format,
+		header:   header,
+		flusher:  flusher,
+	}
+}
+
+func (e *exportWriter) start() error {
+	contentType := "text/csv; charset=utf-8"
+	if e.format == formatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	e.w.Header().Set("Content-Type", contentType)
+	e.w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": e.filename}))
+	// большая выгрузка может писаться дольше WriteTimeout сервера
+	http.NewResponseController(e.w).SetWriteDeadline(time.Time{})
+	e.w.WriteHeader(http.StatusOK)
+
+	if e.format == formatNDJSON {
+		e.json = json.NewEncoder(e.w)
+		return nil
+	}
+	e.csv = csv.NewWriter(e.w)
+	return e.csv.Write(e.header)
+}
+
+// write пишет одну запись: record - строка CSV, v - объект NDJSON
+func (e *exportWriter) write(record []string, v interface{}) error {
+	if e.rows == 0 {
+		if err := e.start(); err != nil {
+			return err
+		}
+	}
+	e.rows++
+
+	var err error
+	if e.csv != nil {
+		for i, value := range record {
+			record[i] = csvSafe(value)
+		}
+		err = e.csv.Write(record)
+	} else {
+		err = e.json.Encode(v)
+	}
+	if err != nil {
+		return err
+	}
+	if e.rows%exportFlushEvery == 0 {
+		e.flush()
+	}
+	return nil
+}
+
+func (e *exportWriter) flush() {
+	if e.csv != nil {
+		e.csv.Flush()
+	}
+	if e.flusher != nil {
+		e.flusher.Flush()
+	}
+}
+
+// finish завершает выгрузку: пустая выгрузка - только заголовок CSV или пустое тело,
+// ошибка до первой строки уходит клиенту обычным ответом
+func (e *exportWriter) finish(err error) {
+	if err != nil {
+		if e.rows == 0 {
+			writeServiceError(e.w, e.r, err)
+			return
+		}
+		slog.ErrorContext(e.r.Context(), "export aborted", "file", e.filename, "rows", e.rows, "error", err)
+		// без завершающего chunk клиент увидит обрыв, а не успешный ответ
+		panic(http.ErrAbortHandler)
+	}
+	if e.rows == 0 {
+		if err := e.start(); err != nil {
+			slog.ErrorContext(e.r.Context(), "export failed", "file", e.filename, "error", err)
+		}
+	}
+	e.flush()
+}
+
+// csvSafe экранирует значения, которые табличные редакторы приняли бы за формулу
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// ExportPRsHandler - выгрузка PR с теми же фильтрами, что у листинга, без пагинации
+func (h *Handlers) ExportPRsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		writeError(w, "BAD_REQUEST", "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	filter, _, err := parsePRListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out := newExportWriter(w, r, format, "pull_requests", []string{
+		"pull_request_id", "pull_request_name", "author_id", "status", "assigned_reviewers", "created_at", "merged_at",
+	})
+	out.finish(h.service.ExportPRs(r.Context(), filter, func(pr models.PullRequest) error {
+		return out.write([]string{
+			pr.PullRequestID, pr.PullRequestName, pr.AuthorID, string(pr.Status),
+			strings.Join(pr.AssignedReviewers, ";"), formatTime(pr.CreatedAt), formatTime(pr.MergedAt),
+		}, pr)
+	}))
+}
+
+// ExportAssignmentsHandler - история назначений и снятий ревьюверов: from/to и team автора PR
+func (h *Handlers) ExportAssignmentsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		writeError(w, "BAD_REQUEST", "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	window, err := parseWindow(query)
+	if err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out := newExportWriter(w, r, format, "assignments", []string{
+		"id", "pull_request_id", "event_type", "user_id", "cause", "actor", "created_at",
+	})
+	out.finish(h.service.ExportAssignments(r.Context(), window, query.Get("team"), func(event models.PREvent) error {
+		return out.write([]string{
+			strconv.FormatInt(event.ID, 10), event.PullRequestID, string(event.EventType), event.UserID,
+			string(event.Cause), event.Actor, formatTime(&event.CreatedAt),
+		}, event)
+	}))
+}
+
+// ExportReviewStatsHandler - статистика ревьюверов целиком: team, subtree и from/to как у /stats/review-counts
+func (h *Handlers) ExportReviewStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		writeError(w, "BAD_REQUEST", "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+	query := r.URL.Query()
+	filter := models.ReviewStatsFilter{
+		TeamName: query.Get("team"),
+		Subtree:  query.Get("subtree") == "true",
+	}
+	var err error
+	if filter.TimeWindow, err = parseWindow(query); err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out := newExportWriter(w, r, format, "review_stats", []string{
+		"user_id", "username", "review_count", "reviews_assigned", "reviews_completed", "prs_authored", "reassigned_away",
+	})
+	out.finish(h.service.ExportReviewStats(r.Context(), filter, func(stat models.ReviewStat) error {
+		return out.write([]string{
+			stat.UserID, stat.Username, strconv.Itoa(stat.ReviewCount), strconv.Itoa(stat.ReviewsAssigned),
+			strconv.Itoa(stat.ReviewsCompleted), strconv.Itoa(stat.PRsAuthored), strconv.Itoa(stat.ReassignedAway),
+		}, stat)
+	}))
+}
@@ -0,0 +1,36 @@
+package httpt
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportWriterEscapesFormulas(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out := newExportWriter(rec, httptest.NewRequest(http.MethodGet, "/export/pull-requests", nil), formatCSV, "pull_requests", []string{"id", "name"})
+
+	require.NoError(t, out.write([]string{"pr-1", "=HYPERLINK(\"http://evil\")"}, nil))
+	require.NoError(t, out.write([]string{"pr-2", "-1+2"}, nil))
+	out.finish(nil)
+
+	assert.Equal(t, "id,name\npr-1,\"'=HYPERLINK(\"\"http://evil\"\")\"\npr-2,'-1+2\n", rec.Body.String())
+}
+
+func TestExportWriterAbortsMidStream(t *testing.T) {
+	rec := httptest.NewRecorder()
+	out := newExportWriter(rec, httptest.NewRequest(http.MethodGet, "/export/pull-requests", nil), formatNDJSON, "pull_requests", nil)
+
+	require.NoError(t, out.write(nil, map[string]string{"id": "pr-1"}))
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { out.finish(errors.New("connection reset")) })
+
+	// ошибка до первой строки - обычный ответ с кодом
+	rec = httptest.NewRecorder()
+	out = newExportWriter(rec, httptest.NewRequest(http.MethodGet, "/export/pull-requests", nil), formatNDJSON, "pull_requests", nil)
+	out.finish(errors.New("connection reset"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+}
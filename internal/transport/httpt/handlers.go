@@ -2,19 +2,30 @@ package httpt
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/events"
 	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/requestid"
 	"github.com/denvyworking/pr-reviewer-service/internal/service"
 )
 
 type Handlers struct {
 	service *service.Service
+	jwt     *auth.JWTVerifier
+	events  *events.Broker
 }
 
-func NewHandlers(service *service.Service) *Handlers {
-	return &Handlers{service: service}
+// NewHandlers: jwt может быть nil - тогда принимаются только API-ключи;
+// broker - тот же, в который публикует сервис
+func NewHandlers(service *service.Service, jwt *auth.JWTVerifier, broker *events.Broker) *Handlers {
+	return &Handlers{service: service, jwt: jwt, events: broker}
 }
 
 func (h *Handlers) CreateTeamHandler(w http.ResponseWriter, r *http.Request) {
@@ -33,8 +44,12 @@ func (h *Handlers) CreateTeamHandler(w http.ResponseWriter, r *http.Request) {
 		switch err {
 		case service.ErrTeamExists:
 			writeError(w, "TEAM_EXISTS", err.Error(), http.StatusBadRequest)
+		case service.ErrNotFound:
+			writeError(w, "NOT_FOUND", "parent team not found", http.StatusNotFound)
+		case service.ErrTeamCycle:
+			writeError(w, "TEAM_CYCLE", err.Error(), http.StatusConflict)
 		default:
-			writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 		}
 		return
 	}
@@ -64,15 +79,87 @@ func (h *Handlers) GetTeamHandler(w http.ResponseWriter, r *http.Request) {
 		case service.ErrNotFound:
 			writeError(w, "NOT_FOUND", "team not found", http.StatusNotFound)
 		default:
-			writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 		}
 		return
 	}
+	if notModified(w, r, team.Version) {
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(team)
 }
 
+func (h *Handlers) SetTeamParentHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		TeamName   string `json:"team_name"`
+		ParentTeam string `json:"parent_team"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.TeamName == "" {
+		writeError(w, "BAD_REQUEST", "team_name is required", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.service.SetTeamParent(r.Context(), request.TeamName, request.ParentTeam)
+	if err != nil {
+		switch err {
+		case service.ErrNotFound:
+			writeError(w, "NOT_FOUND", "team not found", http.StatusNotFound)
+		case service.ErrTeamCycle:
+			writeError(w, "TEAM_CYCLE", err.Error(), http.StatusConflict)
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"team": team,
+	})
+}
+
+func (h *Handlers) GetTeamSubtreeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	teamName := r.URL.Query().Get("team_name")
+	if teamName == "" {
+		writeError(w, "BAD_REQUEST", "team_name is required", http.StatusBadRequest)
+		return
+	}
+
+	tree, err := h.service.GetTeamSubtree(r.Context(), teamName)
+	if err != nil {
+		switch err {
+		case service.ErrNotFound:
+			writeError(w, "NOT_FOUND", "team not found", http.StatusNotFound)
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tree": tree,
+	})
+}
+
 func (h *Handlers) CreatePRHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
@@ -98,11 +185,12 @@ func (h *Handlers) CreatePRHandler(w http.ResponseWriter, r *http.Request) {
 		case service.ErrNotFound:
 			writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
 		default:
-			writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 		}
 		return
 	}
 
+	w.Header().Set("ETag", etag(pr.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -115,10 +203,6 @@ func (h *Handlers) SetIsActiveHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	if !h.isAdminAuthorized(r) {
-		writeError(w, "UNAUTHORIZED", "invalid admin token", http.StatusUnauthorized)
-		return
-	}
 	var user struct {
 		UserID   string `json:"user_id"`
 		IsActive bool   `json:"is_active"`
@@ -133,8 +217,10 @@ func (h *Handlers) SetIsActiveHandler(w http.ResponseWriter, r *http.Request) {
 		switch err {
 		case service.ErrNotFound:
 			writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
+		case service.ErrForbidden:
+			writeError(w, "FORBIDDEN", "user belongs to another team", http.StatusForbidden)
 		default:
-			writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 		}
 		return
 	}
@@ -146,6 +232,101 @@ func (h *Handlers) SetIsActiveHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+func (h *Handlers) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userID := r.URL.Query().Get("user_id")
+	if userID == "" {
+		writeError(w, "BAD_REQUEST", "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.GetUser(r.Context(), userID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": user,
+	})
+}
+
+// ListUsersHandler - справочник пользователей: ?team=, ?is_active=true|false, limit, cursor
+func (h *Handlers) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.UserFilter{TeamName: query.Get("team")}
+	if raw := query.Get("is_active"); raw != "" {
+		isActive, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, "BAD_REQUEST", "is_active must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.IsActive = &isActive
+	}
+	var err error
+	if filter.Limit, err = parseLimit(query); err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	users, next, err := h.service.ListUsers(r.Context(), filter, query.Get("cursor"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"users": users,
+	}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateUserHandler меняет username, team_name и/или is_active; отсутствующие поля не трогаются
+func (h *Handlers) UpdateUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request models.UpdateUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.UserID == "" {
+		writeError(w, "BAD_REQUEST", "user_id is required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.UpdateUser(r.Context(), request)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"user": user,
+	})
+}
+
 func (h *Handlers) MergePRHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
@@ -158,7 +339,7 @@ func (h *Handlers) MergePRHandler(w http.ResponseWriter, r *http.Request) {
 	// он хуже, поэтому далее так не будем)
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
-		writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 	if err := json.Unmarshal(body, &pull_request_id); err != nil {
@@ -169,17 +350,28 @@ func (h *Handlers) MergePRHandler(w http.ResponseWriter, r *http.Request) {
 		writeError(w, "BAD_REQUEST", "pull_request_id is required", http.StatusBadRequest)
 		return
 	}
+	r, ok := withIfMatch(w, r)
+	if !ok {
+		return
+	}
 	pr, err := h.service.MergePR(r.Context(), pull_request_id.PullRequestID)
 	if err != nil {
 		switch err {
 		case service.ErrNotFound:
 			writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
+		case service.ErrPRClosed:
+			writeError(w, "PR_CLOSED", err.Error(), http.StatusConflict)
+		case service.ErrPreconditionFailed:
+			writeError(w, "PRECONDITION_FAILED", err.Error(), http.StatusPreconditionFailed)
+		case service.ErrConflict:
+			writeError(w, "CONFLICT", err.Error(), http.StatusConflict)
 		default:
-			writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 		}
 		return
 	}
 
+	w.Header().Set("ETag", etag(pr.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 
@@ -189,12 +381,12 @@ func (h *Handlers) MergePRHandler(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := json.Marshal(response)
 	if err != nil {
-		writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
 	if _, err := w.Write(resp); err != nil {
-		writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+		writeInternalError(w, r, err)
 		return
 	}
 
@@ -209,27 +401,45 @@ func (h *Handlers) ReassignPRHandler(w http.ResponseWriter, r *http.Request) {
 	var reassign struct {
 		Pr_id      string `json:"pull_request_id"`
 		OldUser_id string `json:"old_user_id"`
+		// manual_reassign (по умолчанию) или escalation
+		Cause models.AssignmentCause `json:"cause"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&reassign); err != nil {
 		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
 		return
 	}
-	pr, newReviewer, err := h.service.ReassignReviewer(r.Context(), reassign.Pr_id, reassign.OldUser_id)
+	r, ok := withIfMatch(w, r)
+	if !ok {
+		return
+	}
+	pr, newReviewer, err := h.service.ReassignReviewer(r.Context(), reassign.Pr_id, reassign.OldUser_id, reassign.Cause)
 	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidRequest):
+			writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+			return
+		}
 		switch err {
 		case service.ErrNotFound:
 			writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
 		case service.ErrPRMerged:
 			writeError(w, "PR_MERGED", err.Error(), http.StatusConflict)
+		case service.ErrPRClosed:
+			writeError(w, "PR_CLOSED", err.Error(), http.StatusConflict)
 		case service.ErrNotAssigned:
 			writeError(w, "NOT_ASSIGNED", err.Error(), http.StatusConflict)
 		case service.ErrNoCandidate:
 			writeError(w, "NO_CANDIDATE", err.Error(), http.StatusConflict)
+		case service.ErrPreconditionFailed:
+			writeError(w, "PRECONDITION_FAILED", err.Error(), http.StatusPreconditionFailed)
+		case service.ErrConflict:
+			writeError(w, "CONFLICT", err.Error(), http.StatusConflict)
 		default:
-			writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 		}
 		return
 	}
+	w.Header().Set("ETag", etag(pr.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
@@ -238,20 +448,172 @@ func (h *Handlers) ReassignPRHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func (h *Handlers) GetReviewHandler(w http.ResponseWriter, r *http.Request) {
+// ClosePRHandler закрывает PR без мержа
+func (h *Handlers) ClosePRHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		PullRequestID string `json:"pull_request_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.PullRequestID == "" {
+		writeError(w, "BAD_REQUEST", "pull_request_id is required", http.StatusBadRequest)
+		return
+	}
+	r, ok := withIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	pr, err := h.service.ClosePR(r.Context(), request.PullRequestID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(pr.Version))
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+// SubmitReviewHandler записывает решение ревьювера: approved, changes_requested или commented
+func (h *Handlers) SubmitReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var request struct {
+		PullRequestID string               `json:"pull_request_id"`
+		ReviewerID    string               `json:"reviewer_id"`
+		Verdict       models.ReviewVerdict `json:"verdict"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.PullRequestID == "" {
+		writeError(w, "BAD_REQUEST", "pull_request_id is required", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.service.SubmitReview(r.Context(), request.PullRequestID, request.ReviewerID, request.Verdict)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"event": event,
+	})
+}
+
+func (h *Handlers) PRHistoryHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
-	userId := r.URL.Query().Get("user_id")
-	pr := h.service.GetReview(r.Context(), userId)
+
+	prID := r.URL.Query().Get("pull_request_id")
+	if prID == "" {
+		writeError(w, "BAD_REQUEST", "pull_request_id is required", http.StatusBadRequest)
+		return
+	}
+
+	events, err := h.service.GetPRHistory(r.Context(), prID)
+	if err != nil {
+		switch err {
+		case service.ErrNotFound:
+			writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
+		default:
+			writeInternalError(w, r, err)
+		}
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"user_id":       userId,
-		"pull_requests": pr,
+		"pull_request_id": prID,
+		"events":          events,
 	})
+}
 
+func (h *Handlers) GetReviewHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	userId := r.URL.Query().Get("user_id")
+	// пользователь, вошедший через OIDC, может не передавать свой user_id
+	if principal := auth.PrincipalFromContext(r.Context()); userId == "" && principal != nil {
+		userId = principal.UserID
+	}
+	if userId == "" {
+		writeError(w, "BAD_REQUEST", "user_id is required", http.StatusBadRequest)
+		return
+	}
+	filter, cursor, err := parseReviewQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+	// старые клиенты не передают limit и ждут весь список
+	if filter.Limit == 0 {
+		filter.Limit = service.Unpaged
+	}
+
+	prs, next, err := h.service.ListReviews(r.Context(), userId, filter, cursor)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_id":       userId,
+		"pull_requests": prs,
+	}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// ListPRsHandler - листинг и поиск PR с фильтрами и пагинацией
+func (h *Handlers) ListPRsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, cursor, err := parsePRListQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prs, next, err := h.service.ListPRs(r.Context(), filter, cursor)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"pull_requests": prs,
+	}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
 }
 
 // GetReviewStatsHandler возвращает статистику по ревьюверам
@@ -261,17 +623,54 @@ func (h *Handlers) GetReviewStatsHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	stats, err := h.service.GetReviewStats(r.Context())
+	// team - фильтр по команде, subtree=true - вместе со всеми дочерними командами,
+	// from/to - период, по которому считается история назначений
+	query := r.URL.Query()
+	filter := models.ReviewStatsFilter{
+		TeamName: query.Get("team"),
+		Subtree:  query.Get("subtree") == "true",
+		Cursor:   query.Get("cursor"),
+	}
+	var err error
+	if filter.Limit, err = parseLimit(query); err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter.TimeWindow, err = parseWindow(query); err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+	// в v1 без limit, как и раньше, отдаётся вся статистика; v2 всегда постраничный
+	if filter.Limit == 0 && !strings.HasPrefix(r.URL.Path, "/v2/") {
+		filter.Limit = service.Unpaged
+	}
+
+	stats, teamStats, next, err := h.service.GetReviewStats(r.Context(), filter)
 	if err != nil {
-		writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, service.ErrNotFound):
+			writeError(w, "NOT_FOUND", "team not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrInvalidRequest):
+			writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		default:
+			writeInternalError(w, r, err)
+		}
 		return
 	}
 
+	response := map[string]interface{}{
+		"stats": stats,
+	}
+	if teamStats != nil {
+		response["teams"] = teamStats
+	}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"stats": stats,
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
 func (h *Handlers) BulkDeactivateHandler(w http.ResponseWriter, r *http.Request) {
@@ -280,11 +679,6 @@ func (h *Handlers) BulkDeactivateHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if !h.isAdminAuthorized(r) {
-		writeError(w, "UNAUTHORIZED", "invalid admin token", http.StatusUnauthorized)
-		return
-	}
-
 	var request models.BulkDeactivateRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
@@ -296,10 +690,12 @@ func (h *Handlers) BulkDeactivateHandler(w http.ResponseWriter, r *http.Request)
 		switch err {
 		case service.ErrNotFound:
 			writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
+		case service.ErrForbidden:
+			writeError(w, "FORBIDDEN", "some users belong to another team", http.StatusForbidden)
 		case service.ErrBulkDeactivateFailed:
 			writeError(w, "BULK_DEACTIVATE_FAILED", "cannot deactivate users - some PRs cannot be reassigned", http.StatusConflict)
 		default:
-			writeError(w, "INTERNAL_ERROR", err.Error(), http.StatusInternalServerError)
+			writeInternalError(w, r, err)
 		}
 		return
 	}
@@ -312,11 +708,6 @@ func (h *Handlers) BulkDeactivateHandler(w http.ResponseWriter, r *http.Request)
 	})
 }
 
-func (h *Handlers) isAdminAuthorized(r *http.Request) bool {
-	authHeader := r.Header.Get("Authorization")
-	return authHeader == "admin-token"
-}
-
 func writeError(w http.ResponseWriter, code, message string, statusCode int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
@@ -324,6 +715,15 @@ func writeError(w http.ResponseWriter, code, message string, statusCode int) {
 	errorResp := models.ErrorResponse{}
 	errorResp.Error.Code = code
 	errorResp.Error.Message = message
+	// заголовок ответа уже выставлен middleware RequestID
+	errorResp.Error.RequestID = w.Header().Get(requestid.Header)
 
 	json.NewEncoder(w).Encode(errorResp)
 }
+
+// writeInternalError пишет причину в лог, а клиенту отдаёт только общий ответ:
+// текст ошибок базы может раскрыть схему и данные. Найти запись в логе можно по request_id
+func writeInternalError(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	writeError(w, "INTERNAL_ERROR", "internal server error", http.StatusInternalServerError)
+}
@@ -0,0 +1,131 @@
+package httpt
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	// тело запроса читается целиком ради хеша, поэтому его размер ограничен
+	maxIdempotentBodySize = 1 << 20
+)
+
+// replayedHeaders - заголовки ответа, которые сохраняются и отдаются при повторе вместе с телом.
+// X-Request-ID и прочие заголовки middleware у повтора свои
+var replayedHeaders = []string{"ETag", "Location"}
+
+// idempotent сохраняет ответ на POST с заголовком Idempotency-Key и при повторе того же
+// запроса отдаёт сохранённый ответ, не выполняя его снова. Тот же ключ с другим телом,
+// путём или query отклоняется. Ответы 5xx не сохраняются - повтор выполнит запрос заново
+func (h *Handlers) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if r.Method != http.MethodPost || key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, "BAD_REQUEST", "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			writeError(w, "BAD_REQUEST", "failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			writeError(w, "BAD_REQUEST", "request body is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		record, err := h.service.BeginIdempotentRequest(r.Context(), key, requestHash(r, body))
+		if err != nil {
+			writeServiceError(w, r, err)
+			return
+		}
+		// StatusCode 0 - ключ зарезервирован под этот запрос, иначе это сохранённый ответ
+		if record.StatusCode != 0 {
+			if record.ContentType != "" {
+				w.Header().Set("Content-Type", record.ContentType)
+			}
+			for name, value := range record.ResponseHeaders {
+				w.Header().Set(name, value)
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(record.StatusCode)
+			w.Write(record.ResponseBody)
+			return
+		}
+
+		// ответ сохраняется, даже если клиент уже отключился - иначе его повтор выполнит запрос ещё раз
+		ctx := context.WithoutCancel(r.Context())
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() {
+			if !completed {
+				if err := h.service.AbortIdempotentRequest(ctx, record); err != nil {
+					slog.ErrorContext(ctx, "failed to release idempotency key", "error", err)
+				}
+			}
+		}()
+
+		next(recorder, r)
+
+		if recorder.status >= http.StatusInternalServerError {
+			return
+		}
+		headers := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				headers[name] = value
+			}
+		}
+		err = h.service.CompleteIdempotentRequest(ctx, record, recorder.status, recorder.Header().Get("Content-Type"), headers, recorder.body.Bytes())
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to store idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write([]byte(strconv.Itoa(len(body)) + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder пропускает ответ клиенту и одновременно запоминает его
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(data []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(data)
+	return rr.ResponseWriter.Write(data)
+}
@@ -0,0 +1,104 @@
+package httpt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/repository"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// idempotencyRepo - ключи идемпотентности в памяти; остальные методы репозитория не нужны
+type idempotencyRepo struct {
+	repository.Repository
+	records map[string]*models.IdempotencyRecord
+	deleted int
+}
+
+func (r *idempotencyRepo) ReserveIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord, staleBefore time.Time) (bool, error) {
+	if _, ok := r.records[record.Key]; ok {
+		return false, nil
+	}
+	stored := *record
+	r.records[record.Key] = &stored
+	return true, nil
+}
+
+func (r *idempotencyRepo) GetIdempotencyKey(ctx context.Context, scope, key string) (*models.IdempotencyRecord, error) {
+	return r.records[key], nil
+}
+
+func (r *idempotencyRepo) CompleteIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	stored := *record
+	r.records[record.Key] = &stored
+	return true, nil
+}
+
+func (r *idempotencyRepo) DeleteIdempotencyKey(ctx context.Context, reservation *models.IdempotencyRecord) error {
+	delete(r.records, reservation.Key)
+	r.deleted++
+	return nil
+}
+
+func TestRequestHash(t *testing.T) {
+	hash := func(target, body string) string {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		return requestHash(r, []byte(body))
+	}
+
+	base := hash("/pullRequest/merge", `{"pull_request_id":"pr-1"}`)
+	assert.Equal(t, base, hash("/pullRequest/merge", `{"pull_request_id":"pr-1"}`))
+	assert.NotEqual(t, base, hash("/pullRequest/merge", `{"pull_request_id":"pr-2"}`))
+	assert.NotEqual(t, base, hash("/pullRequest/close", `{"pull_request_id":"pr-1"}`))
+	assert.NotEqual(t, base, hash("/pullRequest/merge?dry_run=true", `{"pull_request_id":"pr-1"}`))
+}
+
+func TestIdempotentReplayAndAbort(t *testing.T) {
+	repo := &idempotencyRepo{records: map[string]*models.IdempotencyRecord{}}
+	h := NewHandlers(service.NewService(repo), nil, nil)
+
+	calls := 0
+	status := http.StatusInternalServerError
+	handler := h.idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("ETag", etag(int64(calls)))
+		writeJSON(w, status, map[string]interface{}{"call": calls})
+	})
+	send := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/pullRequest/create", strings.NewReader(body))
+		r.Header.Set(IdempotencyKeyHeader, "ci-run-1")
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	// ответ 5xx не сохраняется: ключ освобождается и повтор выполняется заново
+	resp := send(`{"pull_request_id":"pr-1"}`)
+	assert.Equal(t, http.StatusInternalServerError, resp.Code)
+	assert.Equal(t, 1, repo.deleted)
+	assert.Empty(t, repo.records)
+
+	status = http.StatusCreated
+	resp = send(`{"pull_request_id":"pr-1"}`)
+	assert.Equal(t, http.StatusCreated, resp.Code)
+	assert.Equal(t, 2, calls)
+
+	replayed := send(`{"pull_request_id":"pr-1"}`)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, "true", replayed.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, resp.Header().Get("ETag"), replayed.Header().Get("ETag"), "повтор отдаёт ETag исходного ответа")
+	assert.JSONEq(t, resp.Body.String(), replayed.Body.String())
+	assert.Equal(t, 2, calls, "повтор не выполняет запрос ещё раз")
+
+	reused := send(`{"pull_request_id":"pr-2"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Contains(t, reused.Body.String(), "IDEMPOTENCY_KEY_REUSED")
+}
@@ -0,0 +1,82 @@
+package httpt
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/requestid"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// Require аутентифицирует запрос по API-ключу или JWT и пропускает его дальше,
+// только если роли разрешено право perm
+func (h *Handlers) Require(perm auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		if token == "" {
+			writeError(w, "UNAUTHORIZED", "missing credentials", http.StatusUnauthorized)
+			return
+		}
+
+		principal, err := h.authenticate(r, token)
+		if err != nil {
+			switch err {
+			case service.ErrUnauthorized:
+				writeError(w, "UNAUTHORIZED", err.Error(), http.StatusUnauthorized)
+			default:
+				writeInternalError(w, r, err)
+			}
+			return
+		}
+
+		// использование ключа уже видно по last_used_at и журналу аудита, поэтому только Debug
+		if principal.KeyID != "" {
+			slog.DebugContext(r.Context(), "authenticated by api key", "method", r.Method, "path", r.URL.Path, "key_id", principal.KeyID, "key_name", principal.Name)
+		}
+
+		if !principal.Can(perm) {
+			writeError(w, "FORBIDDEN", "missing permission "+string(perm), http.StatusForbidden)
+			return
+		}
+
+		// Idempotency-Key привязан к вызывающему, поэтому проверяется после аутентификации
+		h.idempotent(next)(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	}
+}
+
+func (h *Handlers) authenticate(r *http.Request, token string) (*auth.Principal, error) {
+	if h.jwt == nil || !auth.LooksLikeJWT(token) {
+		return h.service.Authenticate(r.Context(), token)
+	}
+
+	claims, err := h.jwt.Verify(r.Context(), token)
+	if err != nil {
+		return nil, service.ErrUnauthorized
+	}
+	return h.service.AuthenticateUser(r.Context(), claims.Subject)
+}
+
+// bearerToken принимает как "Bearer <key>", так и голый ключ в Authorization
+// (старые клиенты передают его без схемы)
+func bearerToken(r *http.Request) string {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return header
+}
+
+// RequestID берёт X-Request-ID клиента или генерирует новый и кладёт его в контекст,
+// чтобы сервис мог сослаться на запрос в журнале аудита
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if id == "" || len(id) > 100 {
+			id = requestid.Generate()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.NewContext(r.Context(), id)))
+	})
+}
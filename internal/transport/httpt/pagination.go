@@ -0,0 +1,91 @@
+package httpt
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+// parseLimit: пустой limit - размер страницы по умолчанию (его выбирает сервис)
+func parseLimit(query url.Values) (int, error) {
+	raw := query.Get("limit")
+	if raw == "" {
+		return 0, nil
+	}
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit < 0 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	return limit, nil
+}
+
+// parseReviewQuery разбирает параметры списка PR ревьювера:
+// status, created_after, created_before, sort=created_at|-created_at, limit, cursor
+func parseReviewQuery(query url.Values) (models.ReviewFilter, string, error) {
+	filter := models.ReviewFilter{
+		Status: models.PullRequestStatus(strings.ToUpper(query.Get("status"))),
+	}
+
+	var err error
+	if filter.CreatedAfter, err = parseTimeParam(query.Get("created_after")); err != nil {
+		return filter, "", errors.New("created_after must be RFC 3339 timestamp")
+	}
+	if filter.CreatedBefore, err = parseTimeParam(query.Get("created_before")); err != nil {
+		return filter, "", errors.New("created_before must be RFC 3339 timestamp")
+	}
+
+	switch query.Get("sort") {
+	case "", "-created_at":
+	case "created_at":
+		filter.Ascending = true
+	default:
+		return filter, "", errors.New("sort must be created_at or -created_at")
+	}
+
+	if filter.Limit, err = parseLimit(query); err != nil {
+		return filter, "", err
+	}
+
+	return filter, query.Get("cursor"), nil
+}
+
+// parsePRListQuery - параметры /pullRequest/list: общие с parseReviewQuery плюс
+// author_id, team, reviewer_id, merged_after, merged_before и q (подстрока в названии)
+func parsePRListQuery(query url.Values) (models.PRListFilter, string, error) {
+	base, cursor, err := parseReviewQuery(query)
+	if err != nil {
+		return models.PRListFilter{}, "", err
+	}
+
+	filter := models.PRListFilter{
+		ReviewFilter: base,
+		AuthorID:     query.Get("author_id"),
+		TeamName:     query.Get("team"),
+		ReviewerID:   query.Get("reviewer_id"),
+		Query:        strings.TrimSpace(query.Get("q")),
+	}
+	if filter.MergedAfter, err = parseTimeParam(query.Get("merged_after")); err != nil {
+		return filter, "", errors.New("merged_after must be RFC 3339 timestamp")
+	}
+	if filter.MergedBefore, err = parseTimeParam(query.Get("merged_before")); err != nil {
+		return filter, "", errors.New("merged_before must be RFC 3339 timestamp")
+	}
+
+	return filter, cursor, nil
+}
+
+// parseWindow - период статистики: from и to в RFC 3339, любая граница может отсутствовать
+func parseWindow(query url.Values) (models.TimeWindow, error) {
+	var window models.TimeWindow
+	var err error
+	if window.From, err = parseTimeParam(query.Get("from")); err != nil {
+		return window, errors.New("from must be RFC 3339 timestamp")
+	}
+	if window.To, err = parseTimeParam(query.Get("to")); err != nil {
+		return window, errors.New("to must be RFC 3339 timestamp")
+	}
+	return window, nil
+}
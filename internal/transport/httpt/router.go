@@ -0,0 +1,76 @@
+package httpt
+
+import (
+	"net/http"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/auth"
+	"github.com/denvyworking/pr-reviewer-service/internal/transport/graphqlt"
+)
+
+// Routes собирает все маршруты: старые RPC-пути v1 (метод проверяет сам хендлер)
+// и REST-пути v2 с методом и параметрами в шаблоне
+func (h *Handlers) Routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/team/add", h.Require(auth.PermTeamsWrite, h.CreateTeamHandler))
+	mux.HandleFunc("/team/get", h.Require(auth.PermTeamsRead, h.GetTeamHandler))
+	mux.HandleFunc("/team/setParent", h.Require(auth.PermTeamsWrite, h.SetTeamParentHandler))
+	mux.HandleFunc("/team/subtree", h.Require(auth.PermTeamsRead, h.GetTeamSubtreeHandler))
+	mux.HandleFunc("/pullRequest/create", h.Require(auth.PermPRWrite, h.CreatePRHandler))
+	mux.HandleFunc("/pullRequest/merge", h.Require(auth.PermPRWrite, h.MergePRHandler))
+	mux.HandleFunc("/pullRequest/reassign", h.Require(auth.PermPRWrite, h.ReassignPRHandler))
+	mux.HandleFunc("/pullRequest/close", h.Require(auth.PermPRWrite, h.ClosePRHandler))
+	mux.HandleFunc("/pullRequest/review", h.Require(auth.PermPRWrite, h.SubmitReviewHandler))
+	mux.HandleFunc("/pullRequest/history", h.Require(auth.PermPRRead, h.PRHistoryHandler))
+	mux.HandleFunc("/pullRequest/list", h.Require(auth.PermPRRead, h.ListPRsHandler))
+	mux.HandleFunc("/users/setIsActive", h.Require(auth.PermUsersAdmin, h.SetIsActiveHandler))
+	mux.HandleFunc("/users/getReview", h.Require(auth.PermPRRead, h.GetReviewHandler))
+	mux.HandleFunc("/users/get", h.Require(auth.PermUsersRead, h.GetUserHandler))
+	mux.HandleFunc("/users/list", h.Require(auth.PermUsersRead, h.ListUsersHandler))
+	mux.HandleFunc("/users/update", h.Require(auth.PermUsersAdmin, h.UpdateUserHandler))
+	mux.HandleFunc("/stats/review-counts", h.Require(auth.PermStatsRead, h.GetReviewStatsHandler))
+	mux.HandleFunc("/stats/turnaround", h.Require(auth.PermStatsRead, h.GetTurnaroundHandler))
+	mux.HandleFunc("/stats/fairness", h.Require(auth.PermStatsRead, h.GetFairnessHandler))
+	mux.HandleFunc("/stats/pairs", h.Require(auth.PermStatsRead, h.GetReviewPairsHandler))
+	mux.HandleFunc("/export/pull-requests", h.Require(auth.PermPRRead, h.ExportPRsHandler))
+	mux.HandleFunc("/export/assignments", h.Require(auth.PermPRRead, h.ExportAssignmentsHandler))
+	mux.HandleFunc("/export/review-stats", h.Require(auth.PermStatsRead, h.ExportReviewStatsHandler))
+	mux.HandleFunc("/users/bulkDeactivate", h.Require(auth.PermUsersAdmin, h.BulkDeactivateHandler))
+	mux.HandleFunc("/admin/api-keys", h.Require(auth.PermKeysAdmin, h.APIKeysHandler))
+	mux.HandleFunc("/admin/audit", h.Require(auth.PermAuditRead, h.AuditHandler))
+	mux.HandleFunc("GET /events/stream", h.Require(auth.PermPRRead, h.EventsStreamHandler))
+
+	mux.HandleFunc("POST /v2/teams", h.Require(auth.PermTeamsWrite, h.v2CreateTeam))
+	mux.HandleFunc("GET /v2/teams/{name}", h.Require(auth.PermTeamsRead, h.v2GetTeam))
+	mux.HandleFunc("GET /v2/teams/{name}/subtree", h.Require(auth.PermTeamsRead, h.v2GetTeamSubtree))
+	mux.HandleFunc("PUT /v2/teams/{name}/parent", h.Require(auth.PermTeamsWrite, h.v2SetTeamParent))
+	mux.HandleFunc("POST /v2/pull-requests", h.Require(auth.PermPRWrite, h.v2CreatePR))
+	mux.HandleFunc("GET /v2/pull-requests", h.Require(auth.PermPRRead, h.ListPRsHandler))
+	mux.HandleFunc("GET /v2/pull-requests/{id}", h.Require(auth.PermPRRead, h.v2GetPR))
+	mux.HandleFunc("POST /v2/pull-requests/{id}/merge", h.Require(auth.PermPRWrite, h.v2MergePR))
+	mux.HandleFunc("POST /v2/pull-requests/{id}/reassign", h.Require(auth.PermPRWrite, h.v2ReassignPR))
+	mux.HandleFunc("POST /v2/pull-requests/{id}/close", h.Require(auth.PermPRWrite, h.v2ClosePR))
+	mux.HandleFunc("POST /v2/pull-requests/{id}/reviews", h.Require(auth.PermPRWrite, h.v2SubmitReview))
+	mux.HandleFunc("GET /v2/pull-requests/{id}/history", h.Require(auth.PermPRRead, h.v2PRHistory))
+	mux.HandleFunc("GET /v2/users", h.Require(auth.PermUsersRead, h.ListUsersHandler))
+	mux.HandleFunc("GET /v2/users/{id}", h.Require(auth.PermUsersRead, h.v2GetUser))
+	mux.HandleFunc("GET /v2/users/{id}/reviews", h.Require(auth.PermPRRead, h.v2GetUserReviews))
+	mux.HandleFunc("PATCH /v2/users/{id}", h.Require(auth.PermUsersAdmin, h.v2UpdateUser))
+	mux.HandleFunc("POST /v2/users/bulk-deactivate", h.Require(auth.PermUsersAdmin, h.v2BulkDeactivate))
+	mux.HandleFunc("GET /v2/stats/review-counts", h.Require(auth.PermStatsRead, h.GetReviewStatsHandler))
+	mux.HandleFunc("GET /v2/stats/turnaround", h.Require(auth.PermStatsRead, h.GetTurnaroundHandler))
+	mux.HandleFunc("GET /v2/stats/fairness", h.Require(auth.PermStatsRead, h.GetFairnessHandler))
+	mux.HandleFunc("GET /v2/stats/pairs", h.Require(auth.PermStatsRead, h.GetReviewPairsHandler))
+	mux.HandleFunc("GET /v2/export/pull-requests", h.Require(auth.PermPRRead, h.ExportPRsHandler))
+	mux.HandleFunc("GET /v2/export/assignments", h.Require(auth.PermPRRead, h.ExportAssignmentsHandler))
+	mux.HandleFunc("GET /v2/export/review-stats", h.Require(auth.PermStatsRead, h.ExportReviewStatsHandler))
+	mux.HandleFunc("GET /v2/admin/api-keys", h.Require(auth.PermKeysAdmin, h.listAPIKeys))
+	mux.HandleFunc("POST /v2/admin/api-keys", h.Require(auth.PermKeysAdmin, h.createAPIKey))
+	mux.HandleFunc("DELETE /v2/admin/api-keys/{id}", h.Require(auth.PermKeysAdmin, h.v2RevokeAPIKey))
+	mux.HandleFunc("GET /v2/admin/audit", h.Require(auth.PermAuditRead, h.AuditHandler))
+
+	// права на отдельные поля проверяет сама GraphQL-схема
+	mux.HandleFunc("POST /graphql", h.Require(auth.PermTeamsRead, graphqlt.NewHandler(h.service).ServeHTTP))
+
+	return mux
+}
@@ -0,0 +1,100 @@
+package httpt
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+)
+
+// GetTurnaroundHandler - медиана и p90 времени до первого ревью, одобрения и мержа:
+// group_by=team|reviewer, team, from/to и bucket=day|week для временного ряда
+func (h *Handlers) GetTurnaroundHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.TurnaroundFilter{
+		GroupBy:  query.Get("group_by"),
+		TeamName: query.Get("team"),
+		Bucket:   query.Get("bucket"),
+	}
+	var err error
+	if filter.TimeWindow, err = parseWindow(query); err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	groups, err := h.service.GetTurnaround(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"groups": groups,
+	})
+}
+
+// GetFairnessHandler - доли назначений участников команды: team (обязателен), from/to
+// и threshold - допустимое отклонение от равной доли (0.25 - на 25%)
+func (h *Handlers) GetFairnessHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.FairnessFilter{TeamName: query.Get("team")}
+	var err error
+	if filter.TimeWindow, err = parseWindow(query); err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+	if raw := query.Get("threshold"); raw != "" {
+		if filter.Threshold, err = strconv.ParseFloat(raw, 64); err != nil || filter.Threshold <= 0 {
+			writeError(w, "BAD_REQUEST", "threshold must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	report, err := h.service.GetFairness(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, report)
+}
+
+// GetReviewPairsHandler - матрица назначений автор -> ревьювер: team (обязателен) и from/to
+func (h *Handlers) GetReviewPairsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, "METHOD_NOT_ALLOWED", "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := models.PairFilter{TeamName: query.Get("team")}
+	var err error
+	if filter.TimeWindow, err = parseWindow(query); err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pairs, err := h.service.GetReviewPairs(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"team_name": filter.TeamName,
+		"pairs":     pairs,
+	})
+}
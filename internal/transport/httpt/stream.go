@@ -0,0 +1,92 @@
+package httpt
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/events"
+)
+
+// EventsStreamHandler - GET /events/stream (SSE). Фильтры ?team= и ?user_id=,
+// продолжение после обрыва - по заголовку Last-Event-ID
+func (h *Handlers) EventsStreamHandler(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, "INTERNAL_ERROR", "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	var after events.Cursor
+	if raw := r.Header.Get("Last-Event-ID"); raw != "" {
+		cursor, err := events.ParseCursor(raw)
+		if err != nil {
+			writeError(w, "BAD_REQUEST", "Last-Event-ID must be an event id from this stream", http.StatusBadRequest)
+			return
+		}
+		after = cursor
+	}
+
+	filter := events.Filter{
+		TeamName: r.URL.Query().Get("team"),
+		UserID:   r.URL.Query().Get("user_id"),
+	}
+
+	sub, missed, complete := h.events.Subscribe(filter, after)
+	defer h.events.Unsubscribe(sub)
+
+	// поток живёт дольше WriteTimeout сервера
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// часть событий потеряна - клиент должен перечитать состояние через обычный API
+	if !complete {
+		fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+	}
+	for _, e := range missed {
+		if err := writeEvent(w, h.events.Epoch(), e); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	// комментарий-пинг, чтобы прокси не закрывали простаивающее соединение
+	heartbeat := time.NewTicker(h.events.Heartbeat())
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			// брокер закрывает канал отстающего клиента; он переподключится с Last-Event-ID
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, h.events.Epoch(), e); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, epoch string, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	id := events.Cursor{Epoch: epoch, ID: e.ID}
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", id, e.Type, data)
+	return err
+}
@@ -0,0 +1,348 @@
+package httpt
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/denvyworking/pr-reviewer-service/internal/models"
+	"github.com/denvyworking/pr-reviewer-service/internal/service"
+)
+
+// Хендлеры REST API v2. Метод и параметры пути разбирает http.ServeMux,
+// тела ответов совпадают с v1, а ошибки сервиса переводятся в коды единообразно
+
+func (h *Handlers) v2CreateTeam(w http.ResponseWriter, r *http.Request) {
+	var team models.Team
+	if err := json.NewDecoder(r.Body).Decode(&team); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.service.CreateTeam(r.Context(), &team); err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"team": team,
+	})
+}
+
+func (h *Handlers) v2GetTeam(w http.ResponseWriter, r *http.Request) {
+	team, err := h.service.GetTeam(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if notModified(w, r, team.Version) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, team)
+}
+
+func (h *Handlers) v2GetTeamSubtree(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.service.GetTeamSubtree(r.Context(), r.PathValue("name"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"tree": tree,
+	})
+}
+
+func (h *Handlers) v2SetTeamParent(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ParentTeam string `json:"parent_team"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.service.SetTeamParent(r.Context(), r.PathValue("name"), request.ParentTeam)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"team": team,
+	})
+}
+
+func (h *Handlers) v2CreatePR(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PullRequestID   string `json:"pull_request_id"`
+		PullRequestName string `json:"pull_request_name"`
+		AuthorID        string `json:"author_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	pr, err := h.service.CreatePR(r.Context(), request.PullRequestID, request.PullRequestName, request.AuthorID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(pr.Version))
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+func (h *Handlers) v2GetPR(w http.ResponseWriter, r *http.Request) {
+	pr, err := h.service.GetPR(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	if notModified(w, r, pr.Version) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+func (h *Handlers) v2MergePR(w http.ResponseWriter, r *http.Request) {
+	r, ok := withIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	pr, err := h.service.MergePR(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(pr.Version))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+func (h *Handlers) v2ReassignPR(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		OldUserID string                 `json:"old_user_id"`
+		Cause     models.AssignmentCause `json:"cause"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	r, ok := withIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	pr, newReviewer, err := h.service.ReassignReviewer(r.Context(), r.PathValue("id"), request.OldUserID, request.Cause)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(pr.Version))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr":          pr,
+		"replaced_by": newReviewer,
+	})
+}
+
+func (h *Handlers) v2ClosePR(w http.ResponseWriter, r *http.Request) {
+	r, ok := withIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	pr, err := h.service.ClosePR(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+	w.Header().Set("ETag", etag(pr.Version))
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pr": pr,
+	})
+}
+
+func (h *Handlers) v2SubmitReview(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		ReviewerID string               `json:"reviewer_id"`
+		Verdict    models.ReviewVerdict `json:"verdict"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.service.SubmitReview(r.Context(), r.PathValue("id"), request.ReviewerID, request.Verdict)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, map[string]interface{}{
+		"event": event,
+	})
+}
+
+func (h *Handlers) v2PRHistory(w http.ResponseWriter, r *http.Request) {
+	prID := r.PathValue("id")
+	events, err := h.service.GetPRHistory(r.Context(), prID)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"pull_request_id": prID,
+		"events":          events,
+	})
+}
+
+func (h *Handlers) v2GetUserReviews(w http.ResponseWriter, r *http.Request) {
+	userID := r.PathValue("id")
+	filter, cursor, err := parseReviewQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	prs, next, err := h.service.ListReviews(r.Context(), userID, filter, cursor)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"user_id":       userID,
+		"pull_requests": prs,
+	}
+	if next != "" {
+		response["next_cursor"] = next
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handlers) v2GetUser(w http.ResponseWriter, r *http.Request) {
+	user, err := h.service.GetUser(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user": user,
+	})
+}
+
+// v2UpdateUser - частичное обновление: username, team_name и is_active в любом сочетании
+func (h *Handlers) v2UpdateUser(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Username *string `json:"username"`
+		TeamName *string `json:"team_name"`
+		IsActive *bool   `json:"is_active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if request.Username == nil && request.TeamName == nil && request.IsActive == nil {
+		writeError(w, "BAD_REQUEST", "nothing to update", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.service.UpdateUser(r.Context(), models.UpdateUserRequest{
+		UserID:   r.PathValue("id"),
+		Username: request.Username,
+		TeamName: request.TeamName,
+		IsActive: request.IsActive,
+	})
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user": user,
+	})
+}
+
+func (h *Handlers) v2BulkDeactivate(w http.ResponseWriter, r *http.Request) {
+	var request models.BulkDeactivateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, "BAD_REQUEST", "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	deactivated, err := h.service.BulkDeactivateUsers(r.Context(), request.UserIDs)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, models.BulkDeactivateResponse{
+		Message:     "Users deactivated successfully",
+		Deactivated: deactivated,
+	})
+}
+
+func (h *Handlers) v2RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	h.revokeAPIKeyByID(w, r, r.PathValue("id"))
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeServiceError - общее сопоставление ошибок сервиса и HTTP-ответов для v2
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrNotFound):
+		writeError(w, "NOT_FOUND", err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidRequest):
+		writeError(w, "BAD_REQUEST", err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrUnauthorized):
+		writeError(w, "UNAUTHORIZED", err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, service.ErrForbidden):
+		writeError(w, "FORBIDDEN", err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrTeamExists):
+		writeError(w, "TEAM_EXISTS", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrTeamCycle):
+		writeError(w, "TEAM_CYCLE", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrPRExists):
+		writeError(w, "PR_EXISTS", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrPRMerged):
+		writeError(w, "PR_MERGED", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrPRClosed):
+		writeError(w, "PR_CLOSED", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrNotAssigned):
+		writeError(w, "NOT_ASSIGNED", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrNoCandidate):
+		writeError(w, "NO_CANDIDATE", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrBulkDeactivateFailed):
+		writeError(w, "BULK_DEACTIVATE_FAILED", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		writeError(w, "IDEMPOTENCY_KEY_REUSED", err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, service.ErrIdempotencyInFlight):
+		writeError(w, "IDEMPOTENCY_IN_FLIGHT", err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrPreconditionFailed):
+		writeError(w, "PRECONDITION_FAILED", err.Error(), http.StatusPreconditionFailed)
+	case errors.Is(err, service.ErrConflict):
+		writeError(w, "CONFLICT", err.Error(), http.StatusConflict)
+	default:
+		writeInternalError(w, r, err)
+	}
+}
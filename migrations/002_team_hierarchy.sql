@@ -0,0 +1,10 @@
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS parent_team VARCHAR(100) NULL REFERENCES teams(team_name);
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'teams_parent_not_self') THEN
+        ALTER TABLE teams ADD CONSTRAINT teams_parent_not_self CHECK (parent_team <> team_name);
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS idx_teams_parent ON teams(parent_team);
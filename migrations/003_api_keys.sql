@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+    key_id VARCHAR(50) PRIMARY KEY,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('admin', 'team-lead', 'member', 'bot')),
+    user_id VARCHAR(50) NULL REFERENCES users(user_id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
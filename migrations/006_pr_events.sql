@@ -0,0 +1,31 @@
+CREATE TABLE IF NOT EXISTS pr_events (
+    id BIGSERIAL PRIMARY KEY,
+    pull_request_id VARCHAR(100) NOT NULL REFERENCES pull_requests(pull_request_id),
+    event_type VARCHAR(30) NOT NULL,
+    user_id VARCHAR(50) NULL,
+    cause VARCHAR(30) NULL,
+    actor VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pr_events_pr ON pr_events(pull_request_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_pr_events_user ON pr_events(user_id, created_at);
+
+-- PR, созданные до появления истории, получают события из текущего снимка:
+-- назначения на момент миграции считаются сделанными при создании (cause = backfill)
+WITH missing AS (
+    SELECT *
+    FROM pull_requests pr
+    WHERE NOT EXISTS (SELECT 1 FROM pr_events e WHERE e.pull_request_id = pr.pull_request_id)
+)
+INSERT INTO pr_events (pull_request_id, event_type, user_id, cause, actor, created_at)
+SELECT pull_request_id, 'created', NULL, NULL, 'system', COALESCE(created_at, CURRENT_TIMESTAMP)
+FROM missing
+UNION ALL
+SELECT m.pull_request_id, 'reviewer_assigned', r.reviewer, 'backfill', 'system', COALESCE(m.created_at, CURRENT_TIMESTAMP)
+FROM missing m
+CROSS JOIN LATERAL jsonb_array_elements_text(m.assigned_reviewers) AS r(reviewer)
+UNION ALL
+SELECT pull_request_id, 'merged', NULL, NULL, 'system', merged_at
+FROM missing
+WHERE status = 'MERGED' AND merged_at IS NOT NULL;
@@ -0,0 +1,7 @@
+-- Поиск и листинг PR: подстрока в названии через триграммный индекс (ILIKE '%...%'),
+-- фильтры по дате создания и мержа
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_pr_name_trgm ON pull_requests USING gin (pull_request_name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_pr_created_at ON pull_requests(created_at, pull_request_id);
+CREATE INDEX IF NOT EXISTS idx_pr_merged_at ON pull_requests(merged_at);
@@ -0,0 +1,15 @@
+-- Ответы на POST-запросы с заголовком Idempotency-Key. Ключ уникален в пределах вызывающего
+-- (scope - API-ключ или пользователь), status_code IS NULL - запрос ещё выполняется
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(100) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    request_hash CHAR(64) NOT NULL,
+    status_code INT NULL,
+    content_type VARCHAR(100) NULL,
+    response_body BYTEA NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (scope, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires ON idempotency_keys(expires_at);
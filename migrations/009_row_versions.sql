@@ -0,0 +1,46 @@
+-- Версии строк для ETag и условных запросов. Версия растёт при каждом изменении строки,
+-- версия команды - ещё и при любом изменении её участников в users
+ALTER TABLE pull_requests ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE teams ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+-- если UPDATE сам выставил новую версию, триггер её не трогает
+CREATE OR REPLACE FUNCTION bump_row_version() RETURNS trigger AS $$
+BEGIN
+    IF NEW.version = OLD.version THEN
+        NEW.version := OLD.version + 1;
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER pull_requests_bump_version
+    BEFORE UPDATE ON pull_requests
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+
+CREATE OR REPLACE TRIGGER teams_bump_version
+    BEFORE UPDATE ON teams
+    FOR EACH ROW EXECUTE FUNCTION bump_row_version();
+
+-- участники входят в представление команды, поэтому их изменения меняют версию
+-- и старой, и новой команды пользователя
+CREATE OR REPLACE FUNCTION bump_team_version_on_member_change() RETURNS trigger AS $$
+BEGIN
+    IF TG_OP = 'UPDATE' AND OLD IS NOT DISTINCT FROM NEW THEN
+        RETURN NULL;
+    END IF;
+    IF TG_OP = 'INSERT' THEN
+        UPDATE teams SET version = version + 1 WHERE team_name = NEW.team_name;
+        RETURN NULL;
+    END IF;
+
+    UPDATE teams SET version = version + 1 WHERE team_name = OLD.team_name;
+    IF TG_OP = 'UPDATE' AND NEW.team_name <> OLD.team_name THEN
+        UPDATE teams SET version = version + 1 WHERE team_name = NEW.team_name;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER users_bump_team_version
+    AFTER INSERT OR UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION bump_team_version_on_member_change();
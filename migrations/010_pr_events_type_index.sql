@@ -0,0 +1,2 @@
+-- статистика по периодам (/stats/review-counts, /stats/turnaround) выбирает события по типу и времени
+CREATE INDEX IF NOT EXISTS idx_pr_events_type_created ON pr_events(event_type, created_at);
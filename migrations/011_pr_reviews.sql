@@ -0,0 +1,2 @@
+-- решения ревьюверов (review_submitted) хранятся в истории PR вместе с назначениями
+ALTER TABLE pr_events ADD COLUMN IF NOT EXISTS verdict VARCHAR(30) NULL;
@@ -0,0 +1,2 @@
+-- заголовки ответа (ETag), которые нужно вернуть при повторе запроса вместе с телом
+ALTER TABLE idempotency_keys ADD COLUMN IF NOT EXISTS response_headers JSONB NULL;
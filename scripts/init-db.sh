@@ -6,6 +6,8 @@ until pg_isready -h postgres -p 5432 -U user; do
 done
 
 echo "Running database migrations..."
-psql -h postgres -p 5432 -U user -d pr_reviewer -f /migrations/001_init.sql
+for f in /migrations/*.sql; do
+  psql -h postgres -p 5432 -U user -d pr_reviewer -f "$f"
+done
 
 echo "Database initialized successfully!"
\ No newline at end of file
@@ -1,12 +1,16 @@
 package e2e
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,6 +20,32 @@ import (
 
 const baseURL = "http://localhost:8080"
 
+// adminToken - bootstrap admin-ключ, с которым запущен сервис (ADMIN_API_KEY в docker-compose)
+var adminToken = os.Getenv("ADMIN_API_KEY")
+
+func post(path string, body []byte) (*http.Response, error) {
+	return do(http.MethodPost, path, body)
+}
+
+func get(path string) (*http.Response, error) {
+	return do(http.MethodGet, path, nil)
+}
+
+// do отправляет запрос от имени bootstrap admin-ключа
+func do(method, path string, body []byte) (*http.Response, error) {
+	return doAs(adminToken, method, path, body)
+}
+
+func doAs(token, method, path string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
+
 func TestFullFlow(t *testing.T) {
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
@@ -39,7 +69,7 @@ func TestFullFlow(t *testing.T) {
 	}
 
 	teamJSON, _ := json.Marshal(team)
-	resp, err := http.Post(baseURL+"/team/add", "application/json", bytes.NewBuffer(teamJSON))
+	resp, err := post("/team/add", teamJSON)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusCreated, resp.StatusCode, "Создание команды должно быть успешным")
 
@@ -51,7 +81,7 @@ func TestFullFlow(t *testing.T) {
 	}
 
 	prJSON, _ := json.Marshal(prCreate)
-	resp, err = http.Post(baseURL+"/pullRequest/create", "application/json", bytes.NewBuffer(prJSON))
+	resp, err = post("/pullRequest/create", prJSON)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusCreated, resp.StatusCode, "Создание PR должно быть успешным")
 
@@ -65,7 +95,7 @@ func TestFullFlow(t *testing.T) {
 	assert.NotContains(t, reviewers, userID1, "Автор не должен быть в списке ревьюверов")
 
 	// users PRs
-	resp, err = http.Get(baseURL + "/users/getReview?user_id=" + userID2)
+	resp, err = get("/users/getReview?user_id=" + userID2)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode, "Получение PR пользователя должно быть успешным")
 
@@ -80,12 +110,12 @@ func TestFullFlow(t *testing.T) {
 	}
 
 	mergeJSON, _ := json.Marshal(mergeRequest)
-	resp, err = http.Post(baseURL+"/pullRequest/merge", "application/json", bytes.NewBuffer(mergeJSON))
+	resp, err = post("/pullRequest/merge", mergeJSON)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode, "Мерж PR должен быть успешным")
 
 	// Пробуем мержить еще раз (идемпотентность)
-	resp, err = http.Post(baseURL+"/pullRequest/merge", "application/json", bytes.NewBuffer(mergeJSON))
+	resp, err = post("/pullRequest/merge", mergeJSON)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusOK, resp.StatusCode, "Повторный мерж должен быть успешным (идемпотентность)")
 
@@ -96,7 +126,7 @@ func TestFullFlow(t *testing.T) {
 	}
 
 	reassignJSON, _ := json.Marshal(reassignRequest)
-	resp, err = http.Post(baseURL+"/pullRequest/reassign", "application/json", bytes.NewBuffer(reassignJSON))
+	resp, err = post("/pullRequest/reassign", reassignJSON)
 	require.NoError(t, err)
 	assert.Equal(t, http.StatusConflict, resp.StatusCode, "После мержа нельзя менять ревьюверов")
 
@@ -112,11 +142,7 @@ func TestFullFlow(t *testing.T) {
 		}
 
 		bulkJSON, _ := json.Marshal(bulkDeactivateReq)
-		req, _ := http.NewRequest("POST", baseURL+"/users/bulkDeactivate", bytes.NewBuffer(bulkJSON))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "admin-token")
-
-		resp, err := http.DefaultClient.Do(req)
+		resp, err := post("/users/bulkDeactivate", bulkJSON)
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode, "Массовая деактивация должна быть успешной")
 
@@ -129,7 +155,7 @@ func TestFullFlow(t *testing.T) {
 		assert.Contains(t, deactivatedUsers, userID3)
 
 		// Проверяем что пользователи действительно деактивированы
-		resp, err = http.Get(baseURL + "/team/get?team_name=" + teamName)
+		resp, err = get("/team/get?team_name=" + teamName)
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
@@ -145,7 +171,7 @@ func TestFullFlow(t *testing.T) {
 		}
 	})
 	t.Run("ReviewStats", func(t *testing.T) {
-		resp, err := http.Get(baseURL + "/stats/review-counts")
+		resp, err := get("/stats/review-counts")
 		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
 
@@ -154,6 +180,154 @@ func TestFullFlow(t *testing.T) {
 
 		stats := statsResponse["stats"].([]interface{})
 		assert.Greater(t, len(stats), 0, "Должна возвращаться статистика")
+
+		resp, err = get("/stats/review-counts?team=" + teamName + "&limit=2")
+		require.NoError(t, err)
+		var page map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&page)
+		assert.Len(t, page["stats"].([]interface{}), 2)
+		require.NotEmpty(t, page["next_cursor"])
+
+		resp, err = get("/stats/review-counts?team=" + teamName + "&limit=2&cursor=" + page["next_cursor"].(string))
+		require.NoError(t, err)
+		var lastPage map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&lastPage)
+		assert.Len(t, lastPage["stats"].([]interface{}), 1)
+		assert.Nil(t, lastPage["next_cursor"])
+
+		from := time.Unix(timestamp, 0).Add(-time.Minute).UTC().Format(time.RFC3339)
+		resp, err = get("/stats/review-counts?team=" + teamName + "&from=" + from)
+		require.NoError(t, err)
+		var windowed map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&windowed)
+		byUser := map[string]map[string]interface{}{}
+		for _, stat := range windowed["stats"].([]interface{}) {
+			stat := stat.(map[string]interface{})
+			byUser[stat["user_id"].(string)] = stat
+		}
+		assert.Equal(t, float64(1), byUser[userID1]["prs_authored"])
+		assert.Equal(t, float64(1), byUser[userID2]["reviews_completed"], "Смерженный PR засчитывается ревьюверу")
+
+		resp, err = get("/stats/review-counts?from=" + from + "&to=" + from)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Turnaround", func(t *testing.T) {
+		resp, err := get("/stats/turnaround?team=" + teamName + "&bucket=day")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var turnaround map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&turnaround)
+		groups := turnaround["groups"].([]interface{})
+		require.Len(t, groups, 1)
+		group := groups[0].(map[string]interface{})
+		assert.Equal(t, teamName, group["key"])
+		merge := group["time_to_merge"].(map[string]interface{})
+		assert.Equal(t, float64(1), merge["count"])
+		assert.Len(t, group["series"].([]interface{}), 1)
+
+		resp, err = get("/stats/turnaround?group_by=author")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("Fairness", func(t *testing.T) {
+		resp, err := get("/stats/fairness?team=" + teamName)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var report map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&report)
+		assert.Equal(t, float64(2), report["total_assignments"])
+		assert.Nil(t, report["max_min_ratio"], "Автор PR не получил ни одного назначения")
+
+		flags := map[string]interface{}{}
+		for _, member := range report["members"].([]interface{}) {
+			member := member.(map[string]interface{})
+			flags[member["user_id"].(string)] = member["flag"]
+		}
+		assert.Equal(t, "under", flags[userID1])
+		assert.Equal(t, "over", flags[userID2])
+
+		resp, err = get("/stats/fairness")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("ReviewPairs", func(t *testing.T) {
+		resp, err := get("/stats/pairs?team=" + teamName)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var matrix map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&matrix)
+		pairs := matrix["pairs"].([]interface{})
+		require.Len(t, pairs, 2)
+		for _, pair := range pairs {
+			pair := pair.(map[string]interface{})
+			assert.Equal(t, userID1, pair["author_id"])
+			assert.Equal(t, 0.5, pair["author_share"])
+		}
+
+		resp, err = get("/stats/pairs?team=no-such-team")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("Export", func(t *testing.T) {
+		resp, err := get("/export/pull-requests?author_id=" + userID1)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "text/csv")
+		records, err := csv.NewReader(resp.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "pull_request_id", records[0][0])
+		assert.Equal(t, prID, records[1][0])
+
+		resp, err = get("/export/assignments?team=" + teamName + "&format=ndjson")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+		var assigned int
+		decoder := json.NewDecoder(resp.Body)
+		for decoder.More() {
+			var event map[string]interface{}
+			require.NoError(t, decoder.Decode(&event))
+			if event["event_type"] == "reviewer_assigned" {
+				assigned++
+			}
+		}
+		assert.GreaterOrEqual(t, assigned, 2)
+
+		resp, err = get("/export/review-stats?team=" + teamName + "&format=xml")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+		resp, err = get("/export/assignments?team=no-such-team")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("ReviewFilters", func(t *testing.T) {
+		resp, err := get("/users/getReview?user_id=" + userID2 + "&status=MERGED&limit=1")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var reviewPage map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&reviewPage)
+		assert.Len(t, reviewPage["pull_requests"].([]interface{}), 1)
+
+		resp, err = get("/users/getReview?user_id=" + userID2 + "&status=OPEN")
+		require.NoError(t, err)
+		json.NewDecoder(resp.Body).Decode(&reviewPage)
+		assert.Empty(t, reviewPage["pull_requests"])
+
+		resp, err = get("/users/getReview?user_id=" + userID2 + "&cursor=garbage")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
 	})
 	t.Run("SetUserActivity", func(t *testing.T) {
 		setActiveReq := map[string]interface{}{
@@ -162,13 +336,485 @@ func TestFullFlow(t *testing.T) {
 		}
 
 		activeJSON, _ := json.Marshal(setActiveReq)
-		req, _ := http.NewRequest("POST", baseURL+"/users/setIsActive", bytes.NewBuffer(activeJSON))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "admin-token")
+		resp, err := post("/users/setIsActive", activeJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("IdempotencyKey", func(t *testing.T) {
+		idemKey := fmt.Sprintf("e2e-idem-%d", timestamp)
+		send := func(body []byte) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, baseURL+"/pullRequest/create", bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			req.Header.Set("Idempotency-Key", idemKey)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			return resp
+		}
+
+		createJSON, _ := json.Marshal(map[string]interface{}{
+			"pull_request_id":   prID + "-idem",
+			"pull_request_name": "Idempotent create",
+			"author_id":         userID1,
+		})
+		first := send(createJSON)
+		assert.Equal(t, http.StatusCreated, first.StatusCode)
+		var firstBody map[string]interface{}
+		json.NewDecoder(first.Body).Decode(&firstBody)
+
+		retry := send(createJSON)
+		assert.Equal(t, http.StatusCreated, retry.StatusCode, "Повтор не должен давать PR_EXISTS")
+		assert.Equal(t, "true", retry.Header.Get("Idempotent-Replayed"))
+		var retryBody map[string]interface{}
+		json.NewDecoder(retry.Body).Decode(&retryBody)
+		assert.Equal(t, firstBody, retryBody)
+
+		otherJSON, _ := json.Marshal(map[string]interface{}{
+			"pull_request_id":   prID + "-idem-other",
+			"pull_request_name": "Different payload",
+			"author_id":         userID1,
+		})
+		conflict := send(otherJSON)
+		assert.Equal(t, http.StatusUnprocessableEntity, conflict.StatusCode)
+	})
+
+	t.Run("ConditionalRequests", func(t *testing.T) {
+		send := func(method, path, header, value string, body []byte) *http.Response {
+			req, err := http.NewRequest(method, baseURL+path, bytes.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer "+adminToken)
+			req.Header.Set(header, value)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			return resp
+		}
+
+		resp, err := get("/team/get?team_name=" + teamName)
+		require.NoError(t, err)
+		teamTag := resp.Header.Get("ETag")
+		require.NotEmpty(t, teamTag)
+		resp = send(http.MethodGet, "/team/get?team_name="+teamName, "If-None-Match", teamTag, nil)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+		prPath := "/v2/pull-requests/" + prID + "-idem"
+		resp, err = get(prPath)
+		require.NoError(t, err)
+		prTag := resp.Header.Get("ETag")
+		require.NotEmpty(t, prTag)
+		resp = send(http.MethodGet, prPath, "If-None-Match", prTag, nil)
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+		resp = send(http.MethodPost, prPath+"/merge", "If-Match", `"999999"`, nil)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+		resp = send(http.MethodPost, prPath+"/merge", "If-Match", prTag, nil)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.NotEqual(t, prTag, resp.Header.Get("ETag"))
+
+		// переназначение по устаревшему представлению не должно затирать чужое изменение
+		reassignJSON, _ := json.Marshal(map[string]interface{}{"old_user_id": userID2})
+		resp = send(http.MethodPost, prPath+"/reassign", "If-Match", prTag, reassignJSON)
+		assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	})
+
+	t.Run("UserDirectory", func(t *testing.T) {
+		resp, err := get("/users/get?user_id=" + userID1)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = get("/users/list?team=" + teamName + "&is_active=true")
+		require.NoError(t, err)
+		var listResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&listResponse)
+		assert.Len(t, listResponse["users"].([]interface{}), 3)
+
+		updateJSON, _ := json.Marshal(map[string]interface{}{"user_id": userID1, "username": "Renamed User"})
+		resp, err = post("/users/update", updateJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var userResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&userResponse)
+		assert.Equal(t, "Renamed User", userResponse["user"].(map[string]interface{})["username"])
+
+		moveJSON, _ := json.Marshal(map[string]interface{}{"team_name": "no-such-team"})
+		resp, err = do(http.MethodPatch, "/v2/users/"+userID1, moveJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+
+	t.Run("ListPRs", func(t *testing.T) {
+		resp, err := get(fmt.Sprintf("/pullRequest/list?team=%s&author_id=%s&q=%%25e2e+test", teamName, userID1))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var listResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&listResponse)
+		assert.Empty(t, listResponse["pull_requests"], "знак процента в q ищется как обычный символ")
+
+		resp, err = get(fmt.Sprintf("/pullRequest/list?team=%s&q=E2E+TEST&status=MERGED", teamName))
+		require.NoError(t, err)
+		json.NewDecoder(resp.Body).Decode(&listResponse)
+		prs := listResponse["pull_requests"].([]interface{})
+		require.Len(t, prs, 1)
+		assert.Equal(t, prID, prs[0].(map[string]interface{})["pull_request_id"])
+
+		resp, err = get("/v2/pull-requests?reviewer_id=" + userID2 + "&limit=1")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("EventStream", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/events/stream?user_id="+userID3, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		client := &http.Client{Timeout: 10 * time.Second}
+		stream, err := client.Do(req)
+		require.NoError(t, err)
+		defer stream.Body.Close()
+		assert.Equal(t, "text/event-stream", stream.Header.Get("Content-Type"))
+
+		activeJSON, _ := json.Marshal(map[string]interface{}{"user_id": userID3, "is_active": true})
+		resp, err := post("/users/setIsActive", activeJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		scanner := bufio.NewScanner(stream.Body)
+		var eventType string
+		for scanner.Scan() {
+			if name, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+				eventType = name
+				break
+			}
+		}
+		assert.Equal(t, "user.activity_changed", eventType)
+	})
+
+	t.Run("TeamHierarchy", func(t *testing.T) {
+		deptName := fmt.Sprintf("test-dept-%d", timestamp)
+		deptJSON, _ := json.Marshal(map[string]interface{}{
+			"team_name": deptName,
+			"members":   []map[string]interface{}{},
+		})
+		resp, err := post("/team/add", deptJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		parentJSON, _ := json.Marshal(map[string]interface{}{
+			"team_name":   teamName,
+			"parent_team": deptName,
+		})
+		resp, err = post("/team/setParent", parentJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// департамент не может стать дочерним узлом собственной команды
+		cycleJSON, _ := json.Marshal(map[string]interface{}{
+			"team_name":   deptName,
+			"parent_team": teamName,
+		})
+		resp, err = post("/team/setParent", cycleJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, "Цикл в иерархии должен отклоняться")
+
+		resp, err = get("/team/subtree?team_name=" + deptName)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var subtreeResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&subtreeResponse)
+		tree := subtreeResponse["tree"].(map[string]interface{})
+		children := tree["children"].([]interface{})
+		require.Len(t, children, 1)
+		assert.Equal(t, teamName, children[0].(map[string]interface{})["team_name"])
+
+		resp, err = get("/stats/review-counts?subtree=true&team=" + deptName)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var statsResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&statsResponse)
+		assert.Len(t, statsResponse["teams"].([]interface{}), 2, "Статистика по обоим узлам поддерева")
+		assert.Len(t, statsResponse["stats"].([]interface{}), 3, "Только участники поддерева")
+	})
+
+	t.Run("APIKeys", func(t *testing.T) {
+		keyJSON, _ := json.Marshal(map[string]interface{}{
+			"name":   fmt.Sprintf("ci-bot-%d", timestamp),
+			"role":   "bot",
+			"scopes": []string{"pr:read"},
+		})
+		resp, err := post("/admin/api-keys", keyJSON)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var created map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&created)
+		secret := created["secret"].(string)
+		keyID := created["api_key"].(map[string]interface{})["key_id"].(string)
+
+		resp, err = doAs(secret, http.MethodGet, "/users/getReview?user_id="+userID2, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		// stats:read есть у роли bot, но не входит в scopes ключа
+		resp, err = doAs(secret, http.MethodGet, "/stats/review-counts", nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		resp, err = get("/admin/api-keys")
+		require.NoError(t, err)
+		var listResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&listResponse)
+		var listed map[string]interface{}
+		for _, k := range listResponse["api_keys"].([]interface{}) {
+			if k.(map[string]interface{})["key_id"] == keyID {
+				listed = k.(map[string]interface{})
+			}
+		}
+		require.NotNil(t, listed)
+		assert.NotNil(t, listed["last_used_at"], "Использование ключа должно фиксироваться")
+		assert.NotContains(t, listed, "secret")
+
+		resp, err = do(http.MethodDelete, "/admin/api-keys?key_id="+keyID, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = doAs(secret, http.MethodGet, "/users/getReview?user_id="+userID2, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Отозванный ключ не принимается")
+	})
+
+	t.Run("PRHistory", func(t *testing.T) {
+		resp, err := get("/pullRequest/history?pull_request_id=" + prID)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var historyResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&historyResponse)
+		events := historyResponse["events"].([]interface{})
+		require.GreaterOrEqual(t, len(events), 4)
+
+		first := events[0].(map[string]interface{})
+		assert.Equal(t, "created", first["event_type"])
+		assigned := events[1].(map[string]interface{})
+		assert.Equal(t, "reviewer_assigned", assigned["event_type"])
+		assert.Equal(t, "auto_assign", assigned["cause"])
+
+		var types []interface{}
+		for _, e := range events {
+			types = append(types, e.(map[string]interface{})["event_type"])
+		}
+		assert.Contains(t, types, "merged")
+	})
+
+	t.Run("ReviewAndClose", func(t *testing.T) {
+		createJSON, _ := json.Marshal(map[string]interface{}{
+			"pull_request_id":   prID + "-close",
+			"pull_request_name": "Review and close",
+			"author_id":         userID1,
+		})
+		resp, err := post("/pullRequest/create", createJSON)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		var created map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&created)
+		assigned := created["pr"].(map[string]interface{})["assigned_reviewers"].([]interface{})
+		require.NotEmpty(t, assigned)
+
+		reviewJSON, _ := json.Marshal(map[string]interface{}{
+			"pull_request_id": prID + "-close",
+			"reviewer_id":     assigned[0],
+			"verdict":         "changes_requested",
+		})
+		resp, err = post("/pullRequest/review", reviewJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+		authorJSON, _ := json.Marshal(map[string]interface{}{"reviewer_id": userID1, "verdict": "approved"})
+		resp, err = post("/v2/pull-requests/"+prID+"-close/reviews", authorJSON)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode, "автор не назначен ревьювером")
+
+		reviewCount := func() float64 {
+			resp, err := get("/stats/review-counts?team=" + teamName)
+			require.NoError(t, err)
+			var statsResponse map[string]interface{}
+			json.NewDecoder(resp.Body).Decode(&statsResponse)
+			for _, stat := range statsResponse["stats"].([]interface{}) {
+				stat := stat.(map[string]interface{})
+				if stat["user_id"] == assigned[0] {
+					return stat["review_count"].(float64)
+				}
+			}
+			return 0
+		}
+		openReviews := reviewCount()
+
+		resp, err = post("/v2/pull-requests/"+prID+"-close/close", nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, openReviews-1, reviewCount(), "Закрытый PR не считается открытым ревью")
+
+		resp, err = post("/v2/pull-requests/"+prID+"-close/merge", nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusConflict, resp.StatusCode)
+		var errorResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&errorResponse)
+		assert.Equal(t, "PR_CLOSED", errorResponse["error"].(map[string]interface{})["code"])
 
+		resp, err = get("/pullRequest/history?pull_request_id=" + prID + "-close")
+		require.NoError(t, err)
+		var historyResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&historyResponse)
+		var verdicts, types []interface{}
+		for _, e := range historyResponse["events"].([]interface{}) {
+			event := e.(map[string]interface{})
+			types = append(types, event["event_type"])
+			if v, ok := event["verdict"]; ok {
+				verdicts = append(verdicts, v)
+			}
+		}
+		assert.Contains(t, types, "review_submitted")
+		assert.Contains(t, types, "closed")
+		assert.Equal(t, []interface{}{"changes_requested"}, verdicts)
+	})
+
+	t.Run("RESTv2", func(t *testing.T) {
+		resp, err := get("/v2/teams/" + teamName)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = get("/v2/pull-requests/" + prID)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var prResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&prResponse)
+		assert.Equal(t, "MERGED", prResponse["pr"].(map[string]interface{})["status"])
+
+		resp, err = post("/v2/pull-requests/"+prID+"/merge", nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode, "Повторный мерж идемпотентен и в v2")
+
+		resp, err = get("/v2/users/" + userID2 + "/reviews")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = do(http.MethodPut, "/v2/pull-requests/"+prID, nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+	})
+
+	t.Run("AuditLog", func(t *testing.T) {
+		resp, err := get("/admin/audit?target_type=pull_request&target_id=" + prID)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var auditResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&auditResponse)
+
+		var actions []interface{}
+		for _, e := range auditResponse["entries"].([]interface{}) {
+			entry := e.(map[string]interface{})
+			actions = append(actions, entry["action"])
+			assert.NotEmpty(t, entry["actor"])
+			assert.NotEmpty(t, entry["request_id"])
+		}
+		assert.Contains(t, actions, "pr.create")
+		assert.Contains(t, actions, "pr.merge")
+
+		resp, err = get("/admin/audit?limit=1")
+		require.NoError(t, err)
+		var page map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&page)
+		assert.Len(t, page["entries"].([]interface{}), 1)
+		assert.NotEmpty(t, page["next_cursor"])
+	})
+
+	t.Run("GraphQL", func(t *testing.T) {
+		query, _ := json.Marshal(map[string]interface{}{
+			"query": `query($name: String!) {
+				team(name: $name) {
+					name
+					members { id isActive reviewCount pendingReviews { id author { id } reviewers { id } } }
+				}
+			}`,
+			"variables": map[string]interface{}{"name": teamName},
+		})
+		resp, err := post("/graphql", query)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var gqlResponse map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&gqlResponse)
+		assert.Nil(t, gqlResponse["errors"])
+
+		data := gqlResponse["data"].(map[string]interface{})
+		gqlTeam := data["team"].(map[string]interface{})
+		assert.Equal(t, teamName, gqlTeam["name"])
+		assert.Len(t, gqlTeam["members"].([]interface{}), 3)
+
+		missing, _ := json.Marshal(map[string]interface{}{"query": `{ pullRequest(id: "no-such-pr") { id } }`})
+		resp, err = post("/graphql", missing)
+		require.NoError(t, err)
+		json.NewDecoder(resp.Body).Decode(&gqlResponse)
+		assert.Nil(t, gqlResponse["data"].(map[string]interface{})["pullRequest"])
+	})
+
+	t.Run("RequestID", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, baseURL+"/v2/pull-requests/no-such-pr", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+adminToken)
+		req.Header.Set("X-Request-ID", "e2e-request-id")
 		resp, err := http.DefaultClient.Do(req)
 		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+		assert.Equal(t, "e2e-request-id", resp.Header.Get("X-Request-ID"))
+
+		var errResp map[string]map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&errResp)
+		assert.Equal(t, "e2e-request-id", errResp["error"]["request_id"])
+
+		resp, err = get("/v2/pull-requests/no-such-pr")
+		require.NoError(t, err)
+		assert.Len(t, resp.Header.Get("X-Request-ID"), 32, "без заголовка клиента id генерируется")
+	})
+
+	t.Run("Probes", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/livez")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		resp, err = http.Get(baseURL + "/readyz")
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var readiness map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&readiness)
+		assert.Equal(t, "ready", readiness["status"])
+		assert.Equal(t, "ok", readiness["checks"].(map[string]interface{})["database"])
+		assert.Contains(t, readiness["workers"], "idempotency_purger")
+	})
+
+	t.Run("Metrics", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/metrics")
+		require.NoError(t, err)
 		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `pr_reviewer_http_requests_total{method="POST",route="/pullRequest/create",status="201"}`)
+		assert.Contains(t, string(body), `pr_reviewer_pull_request_changes_total{action="merged"}`)
+		assert.Contains(t, string(body), `pr_reviewer_open_pull_requests{team="`+teamName+`"}`)
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		resp, err := http.Get(baseURL + "/team/get?team_name=" + teamName)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "Без ключа доступ запрещён")
 	})
 
 	slog.Info("ВСЕ E2E ТЕСТЫ ПРОЙДЕНЫ УСПЕШНО!")
@@ -0,0 +1,67 @@
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	reviewerv1 "github.com/denvyworking/pr-reviewer-service/api/reviewer/v1"
+)
+
+const grpcAddr = "localhost:9090"
+
+func TestGRPC(t *testing.T) {
+	conn, err := grpc.NewClient(grpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	client := reviewerv1.NewReviewerServiceClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = client.GetTeam(ctx, &reviewerv1.GetTeamRequest{TeamName: "any"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err), "Без ключа доступ запрещён")
+
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+adminToken)
+
+	timestamp := time.Now().UnixNano()
+	teamName := fmt.Sprintf("grpc-team-%d", timestamp)
+	authorID := fmt.Sprintf("grpc-author-%d", timestamp)
+	reviewerID := fmt.Sprintf("grpc-reviewer-%d", timestamp)
+
+	_, err = client.CreateTeam(ctx, &reviewerv1.CreateTeamRequest{Team: &reviewerv1.Team{
+		TeamName: teamName,
+		Members: []*reviewerv1.TeamMember{
+			{UserId: authorID, Username: "gRPC Author", IsActive: true},
+			{UserId: reviewerID, Username: "gRPC Reviewer", IsActive: true},
+		},
+	}})
+	require.NoError(t, err)
+
+	created, err := client.CreatePullRequest(ctx, &reviewerv1.CreatePullRequestRequest{
+		PullRequestId:   fmt.Sprintf("grpc-pr-%d", timestamp),
+		PullRequestName: "gRPC feature",
+		AuthorId:        authorID,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{reviewerID}, created.GetPr().GetAssignedReviewers())
+
+	// единственного ревьювера некем заменить
+	_, err = client.ReassignReviewer(ctx, &reviewerv1.ReassignReviewerRequest{
+		PullRequestId: created.GetPr().GetPullRequestId(),
+		OldUserId:     reviewerID,
+	})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	_, err = client.GetPullRequest(ctx, &reviewerv1.GetPullRequestRequest{PullRequestId: "missing-pr"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}